@@ -0,0 +1,153 @@
+package deepdiff
+
+import "bytes"
+
+// alignSimilarity is the similarity two arrays or objects between anchors
+// need to be paired when no similarity threshold is configured
+const alignSimilarity = 0.5
+
+// maxRunPairs bounds the size of a run of deletes & inserts that's searched
+// for the best order-preserving pairing. Larger runs are paired by position
+const maxRunPairs = 1 << 16
+
+// maxAlignEdits bounds the number of differences alignment will search for
+// before giving up. Myers uses O(D^2) space for D differences
+const maxAlignEdits = 1024
+
+// alignChildren matches the children of two ordered arrays by aligning them
+// as sequences of child hashes. Equal elements are matched as anchors, and
+// runs of deleted & inserted elements between anchors are paired up in order,
+// so modified elements are diffed in place. Arrays & objects are only paired
+// if they're similar enough to read as a modification instead of a
+// replacement. Any existing matches of children are replaced.
+// alignChildren returns false without changing any matches if alignment gives
+// up, or if arrays of the same length only differ by elements modified in
+// place, which are better matched by position
+func (d *diff) alignChildren(a, b *array) bool {
+	ach, bch := a.Children(), b.Children()
+	edits, ok := boundedMyers(len(ach), len(bch), maxAlignEdits, func(i, j int) bool {
+		return bytes.Equal(ach[i].Hash(), bch[j].Hash())
+	})
+	if !ok || (len(ach) == len(bch) && balanced(edits)) {
+		return false
+	}
+
+	for _, ch := range ach {
+		d.unmatch(ch)
+	}
+	for _, ch := range bch {
+		d.unmatch(ch)
+	}
+
+	var dels, ins []node
+	flush := func() {
+		for _, p := range d.pairRun(dels, ins) {
+			d.setMatch(p[0], p[1])
+			d.setMatch(p[1], p[0])
+		}
+		dels, ins = dels[:0], ins[:0]
+	}
+
+	for _, e := range edits {
+		switch e.op {
+		case DTContext:
+			flush()
+			d.setMatch(ach[e.aIdx], bch[e.bIdx])
+			d.setMatch(bch[e.bIdx], ach[e.aIdx])
+		case DTDelete:
+			dels = append(dels, ach[e.aIdx])
+		case DTInsert:
+			ins = append(ins, bch[e.bIdx])
+		}
+	}
+	flush()
+	return true
+}
+
+// balanced returns true if every run of changes in an edit script deletes as
+// many elements as it inserts
+func balanced(edits []seqEdit) bool {
+	run := 0
+	for _, e := range edits {
+		switch e.op {
+		case DTContext:
+			if run != 0 {
+				return false
+			}
+		case DTDelete:
+			run++
+		case DTInsert:
+			run--
+		}
+	}
+	return run == 0
+}
+
+// pairRun pairs deleted & inserted elements between two anchors, keeping
+// their order. Small runs pick the order-preserving pairing with the most
+// alignable pairs, preferring to pair earlier elements on ties. Large runs
+// only pair elements in the same position of the run
+func (d *diff) pairRun(dels, ins []node) (pairs [][2]node) {
+	if len(dels) == 0 || len(ins) == 0 {
+		return nil
+	}
+	if len(dels)*len(ins) > maxRunPairs {
+		for i := 0; i < len(dels) && i < len(ins); i++ {
+			if d.alignable(dels[i], ins[i]) {
+				pairs = append(pairs, [2]node{dels[i], ins[i]})
+			}
+		}
+		return pairs
+	}
+
+	// best[i][j] is the most pairs found in dels[i:] & ins[j:]
+	n, m := len(dels), len(ins)
+	best := make([][]int, n+1)
+	for i := range best {
+		best[i] = make([]int, m+1)
+	}
+	ok := make([][]bool, n)
+	for i := n - 1; i >= 0; i-- {
+		ok[i] = make([]bool, m)
+		for j := m - 1; j >= 0; j-- {
+			ok[i][j] = d.alignable(dels[i], ins[j])
+			best[i][j] = best[i+1][j]
+			if best[i][j+1] > best[i][j] {
+				best[i][j] = best[i][j+1]
+			}
+			if ok[i][j] && best[i+1][j+1]+1 > best[i][j] {
+				best[i][j] = best[i+1][j+1] + 1
+			}
+		}
+	}
+
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case ok[i][j] && best[i][j] == best[i+1][j+1]+1:
+			pairs = append(pairs, [2]node{dels[i], ins[j]})
+			i++
+			j++
+		case best[i][j] == best[i+1][j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// alignable checks if two nodes in the same position of aligned arrays should
+// be matched
+func (d *diff) alignable(a, b node) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	if _, ok := a.(compound); !ok {
+		return true
+	}
+	threshold := d.similarityThreshold
+	if threshold <= 0 {
+		threshold = alignSimilarity
+	}
+	return similarity(a, b) >= threshold
+}
@@ -0,0 +1,94 @@
+package deepdiff
+
+import "testing"
+
+func TestArrayAlignment(t *testing.T) {
+	cases := []TestCase{
+		{"insert before modified records",
+			`[{"id":1,"name":"a","n":1},{"id":2,"name":"b","n":2},{"id":3,"name":"c","n":3}]`,
+			`[{"id":0,"name":"new","n":0},{"id":1,"name":"a","n":10},{"id":2,"name":"b","n":20},{"id":3,"name":"c","n":30}]`,
+			Deltas{
+				{Type: DTInsert, Path: IndexAddr(0), Value: map[string]interface{}{"id": float64(0), "name": "new", "n": float64(0)}},
+				{Type: DTContext, Path: IndexAddr(1), Deltas: Deltas{
+					{Type: DTContext, Path: StringAddr("id"), Value: float64(1)},
+					{Type: DTUpdate, Path: StringAddr("n"), SourceValue: float64(1), Value: float64(10)},
+					{Type: DTContext, Path: StringAddr("name"), Value: "a"},
+				}},
+				{Type: DTContext, Path: IndexAddr(2), Deltas: Deltas{
+					{Type: DTContext, Path: StringAddr("id"), Value: float64(2)},
+					{Type: DTUpdate, Path: StringAddr("n"), SourceValue: float64(2), Value: float64(20)},
+					{Type: DTContext, Path: StringAddr("name"), Value: "b"},
+				}},
+				{Type: DTContext, Path: IndexAddr(3), Deltas: Deltas{
+					{Type: DTContext, Path: StringAddr("id"), Value: float64(3)},
+					{Type: DTUpdate, Path: StringAddr("n"), SourceValue: float64(3), Value: float64(30)},
+					{Type: DTContext, Path: StringAddr("name"), Value: "c"},
+				}},
+			},
+		},
+		{"shifted scalars",
+			`[1,2,3,4,5]`,
+			`[0,1,2,"x",4,5]`,
+			Deltas{
+				{Type: DTInsert, Path: IndexAddr(0), Value: float64(0)},
+				{Type: DTContext, Path: IndexAddr(1), Value: float64(1)},
+				{Type: DTContext, Path: IndexAddr(2), Value: float64(2)},
+				{Type: DTDelete, Path: IndexAddr(3), Value: float64(3)},
+				{Type: DTInsert, Path: IndexAddr(3), Value: "x"},
+				{Type: DTContext, Path: IndexAddr(4), Value: float64(4)},
+				{Type: DTContext, Path: IndexAddr(5), Value: float64(5)},
+			},
+		},
+		{"dissimilar records aren't paired",
+			`[{"a":1,"b":2},{"c":3,"d":4}]`,
+			`[{"a":1,"b":2},{"e":5},{"f":6}]`,
+			Deltas{
+				{Type: DTContext, Path: IndexAddr(0), Value: map[string]interface{}{"a": float64(1), "b": float64(2)}},
+				{Type: DTDelete, Path: IndexAddr(1), Value: map[string]interface{}{"c": float64(3), "d": float64(4)}},
+				{Type: DTInsert, Path: IndexAddr(1), Value: map[string]interface{}{"e": float64(5)}},
+				{Type: DTInsert, Path: IndexAddr(2), Value: map[string]interface{}{"f": float64(6)}},
+			},
+		},
+	}
+
+	RunTestCases(t, cases, func(cfg *Config) { cfg.CalcChanges = true })
+}
+
+func TestBoundedMyers(t *testing.T) {
+	a := []int{1, 2, 3, 4, 5, 6}
+	b := []int{6, 5, 4, 3, 2, 1}
+	eq := func(i, j int) bool { return a[i] == b[j] }
+
+	if _, ok := boundedMyers(len(a), len(b), 4, eq); ok {
+		t.Error("expected alignment to give up")
+	}
+	edits, ok := boundedMyers(len(a), len(b), -1, eq)
+	if !ok {
+		t.Fatal("expected unbounded alignment to finish")
+	}
+	if len(edits) != len(myers(len(a), len(b), eq)) {
+		t.Errorf("expected unbounded result to match myers")
+	}
+}
+
+func TestBalancedEdits(t *testing.T) {
+	cases := []struct {
+		ops    []Operation
+		expect bool
+	}{
+		{[]Operation{DTContext, DTContext}, true},
+		{[]Operation{DTDelete, DTInsert, DTContext, DTInsert, DTDelete}, true},
+		{[]Operation{DTInsert, DTContext, DTDelete}, false},
+		{[]Operation{DTDelete, DTDelete, DTInsert}, false},
+	}
+
+	for i, c := range cases {
+		edits := make([]seqEdit, len(c.ops))
+		for j, op := range c.ops {
+			edits[j] = seqEdit{op: op}
+		}
+		if got := balanced(edits); got != c.expect {
+			t.Errorf("case %d: expected %t, got %t", i, c.expect, got)
+		}
+	}
+}
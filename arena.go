@@ -0,0 +1,133 @@
+package deepdiff
+
+import "hash"
+
+// arenaBlock is the largest number of values allocated at once by an arena.
+// blocks start small & double in size up to arenaBlock, so small documents
+// don't pay for large blocks
+const arenaBlock = 512
+
+// nextBlock is the size of the next block to allocate after a block of size n
+func nextBlock(n int) int {
+	if n == 0 {
+		return 8
+	}
+	if n*2 > arenaBlock {
+		return arenaBlock
+	}
+	return n * 2
+}
+
+// arena allocates the nodes of a tree in blocks, replacing an allocation per
+// node with one per block. Hashes are packed into shared byte blocks, and
+// short child lists are sliced from a shared block of nodes. An arena also
+// lists every node built with it in bottom-up order. arenas aren't safe for
+// concurrent use, parallel builds use an arena per goroutine.
+//
+// arenas only batch allocations. Nodes are still linked by pointer through
+// the node interface, hashes are variable-length byte slices sized by the
+// configured hash function, and children are slices of nodes. Addressing
+// nodes by integer ID, with fixed-size hashes & children as index ranges,
+// would shrink nodes further, but means replacing the node interface
+// throughout the package
+type arena struct {
+	nodes []node
+
+	scalars  []scalar
+	arrays   []array
+	objects  []object
+	values   []jsonValue
+	children []node
+	hashes   []byte
+	hasher   hash.Hash
+	newHash  func() hash.Hash
+	// scratch buffer for writing strings to hashes
+	buf []byte
+}
+
+func newArena(newHash func() hash.Hash) *arena {
+	return &arena{newHash: newHash}
+}
+
+func (a *arena) scalar() *scalar {
+	if len(a.scalars) == cap(a.scalars) {
+		a.scalars = make([]scalar, 0, nextBlock(cap(a.scalars)))
+	}
+	a.scalars = a.scalars[:len(a.scalars)+1]
+	return &a.scalars[len(a.scalars)-1]
+}
+
+func (a *arena) array() *array {
+	if len(a.arrays) == cap(a.arrays) {
+		a.arrays = make([]array, 0, nextBlock(cap(a.arrays)))
+	}
+	a.arrays = a.arrays[:len(a.arrays)+1]
+	return &a.arrays[len(a.arrays)-1]
+}
+
+func (a *arena) object() *object {
+	if len(a.objects) == cap(a.objects) {
+		a.objects = make([]object, 0, nextBlock(cap(a.objects)))
+	}
+	a.objects = a.objects[:len(a.objects)+1]
+	return &a.objects[len(a.objects)-1]
+}
+
+// jsonValue allocates a value encoded in src from start to end
+func (a *arena) jsonValue(src *jsonSource, start, end int64) *jsonValue {
+	if len(a.values) == cap(a.values) {
+		a.values = make([]jsonValue, 0, nextBlock(cap(a.values)))
+	}
+	a.values = a.values[:len(a.values)+1]
+	v := &a.values[len(a.values)-1]
+	v.src, v.start, v.end = src, start, end
+	return v
+}
+
+// childList allocates a list of n children. Lists are capped at their length,
+// so appending to one never writes into a neighbouring list
+func (a *arena) childList(n int) []node {
+	if n > arenaBlock/4 {
+		return make([]node, n)
+	}
+	if cap(a.children)-len(a.children) < n {
+		a.children = make([]node, 0, arenaBlock)
+	}
+	start := len(a.children)
+	a.children = a.children[:start+n]
+	return a.children[start : start+n : start+n]
+}
+
+// hash returns a reset hash function. The same hash is reused for every call,
+// so it must be summed before hash is called again
+func (a *arena) hash() hash.Hash {
+	if a.hasher == nil {
+		a.hasher = a.newHash()
+	}
+	a.hasher.Reset()
+	return a.hasher
+}
+
+// sum writes the sum of h to the arena's hash block
+func (a *arena) sum(h hash.Hash) []byte {
+	size := h.Size()
+	if cap(a.hashes)-len(a.hashes) < size {
+		block := nextBlock(cap(a.hashes)/size) * size
+		a.hashes = make([]byte, 0, block)
+	}
+	start := len(a.hashes)
+	a.hashes = h.Sum(a.hashes)
+	return a.hashes[start:len(a.hashes):len(a.hashes)]
+}
+
+// writeString writes a string to a hash without allocating a byte slice for
+// each write
+func (a *arena) writeString(h hash.Hash, str string) {
+	a.buf = append(a.buf[:0], str...)
+	h.Write(a.buf)
+}
+
+// add lists a node as built
+func (a *arena) add(n node) {
+	a.nodes = append(a.nodes, n)
+}
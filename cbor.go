@@ -0,0 +1,654 @@
+package deepdiff
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"sort"
+	"unicode/utf8"
+)
+
+// CBOR major types, from RFC 8949 section 3.1
+const (
+	cborUint   = 0
+	cborNegint = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+const (
+	// cborIndefinite is the additional info of items with indefinite length
+	cborIndefinite = 31
+	// cborBreak ends items of indefinite length
+	cborBreak = 0xff
+	// cborMaxDepth bounds how deeply items can nest while decoding
+	cborMaxDepth = 1024
+)
+
+// DecodeCBOR reads a single CBOR data item (RFC 8949) as the go types diff
+// trees are built from. Integers decode as int64, or float64 when they don't
+// fit. Byte strings decode as []byte. Map keys must be text strings or
+// integers, integer keys are written in decimal. Tags are dropped, keeping
+// the tagged value, except bignums which decode as float64. Undefined decodes
+// as null
+func DecodeCBOR(r io.Reader) (interface{}, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	dec := &cborDecoder{data: data}
+	v, err := dec.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if dec.pos != len(data) {
+		return nil, fmt.Errorf("invalid CBOR: unexpected data after top-level value")
+	}
+	return v, nil
+}
+
+// DiffCBOR computes deltas between two CBOR documents, decoded with
+// DecodeCBOR
+func (dd *DeepDiff) DiffCBOR(ctx context.Context, r1, r2 io.Reader) (Deltas, error) {
+	a, err := DecodeCBOR(r1)
+	if err != nil {
+		return nil, err
+	}
+	b, err := DecodeCBOR(r2)
+	if err != nil {
+		return nil, err
+	}
+	return dd.Diff(ctx, a, b)
+}
+
+// cborDecoder reads CBOR data items from a buffer
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+var errCBORBreak = fmt.Errorf("invalid CBOR: unexpected break")
+
+// head reads the initial byte & argument of a data item. For items of
+// indefinite length arg is 0 & indefinite is true
+func (dec *cborDecoder) head() (major byte, info byte, arg uint64, indefinite bool, err error) {
+	if dec.pos >= len(dec.data) {
+		return 0, 0, 0, false, io.ErrUnexpectedEOF
+	}
+	b := dec.data[dec.pos]
+	dec.pos++
+	major, info = b>>5, b&0x1f
+
+	switch {
+	case info < 24:
+		return major, info, uint64(info), false, nil
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(dec.data)-dec.pos < size {
+			return 0, 0, 0, false, io.ErrUnexpectedEOF
+		}
+		buf := dec.data[dec.pos : dec.pos+size]
+		dec.pos += size
+		switch size {
+		case 1:
+			arg = uint64(buf[0])
+		case 2:
+			arg = uint64(binary.BigEndian.Uint16(buf))
+		case 4:
+			arg = uint64(binary.BigEndian.Uint32(buf))
+		default:
+			arg = binary.BigEndian.Uint64(buf)
+		}
+		return major, info, arg, false, nil
+	case info == cborIndefinite && major >= cborBytes && major <= cborMap:
+		return major, info, 0, true, nil
+	case info == cborIndefinite && major == cborSimple:
+		return 0, 0, 0, false, errCBORBreak
+	}
+	return 0, 0, 0, false, fmt.Errorf("invalid CBOR: reserved additional info %d", info)
+}
+
+// length checks a length read from a data item fits in the remaining data
+// when each element takes at least min bytes
+func (dec *cborDecoder) length(arg uint64, min int) (int, error) {
+	if arg > uint64(len(dec.data)-dec.pos)/uint64(min) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return int(arg), nil
+}
+
+// atBreak consumes a break if it's the next byte
+func (dec *cborDecoder) atBreak() (bool, error) {
+	if dec.pos >= len(dec.data) {
+		return false, io.ErrUnexpectedEOF
+	}
+	if dec.data[dec.pos] == cborBreak {
+		dec.pos++
+		return true, nil
+	}
+	return false, nil
+}
+
+func (dec *cborDecoder) value(depth int) (interface{}, error) {
+	if depth > cborMaxDepth {
+		return nil, fmt.Errorf("invalid CBOR: exceeded max depth of %d", cborMaxDepth)
+	}
+	major, info, arg, indefinite, err := dec.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUint:
+		return uint64ToNumber(arg), nil
+	case cborNegint:
+		if arg > math.MaxInt64 {
+			return -1 - float64(arg), nil
+		}
+		return -1 - int64(arg), nil
+	case cborBytes, cborText:
+		str, err := dec.str(major, arg, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborBytes {
+			return str, nil
+		}
+		if !utf8.Valid(str) {
+			return nil, fmt.Errorf("invalid CBOR: text string isn't valid UTF-8")
+		}
+		return string(str), nil
+	case cborArray:
+		return dec.array(arg, indefinite, depth)
+	case cborMap:
+		return dec.object(arg, indefinite, depth)
+	case cborTag:
+		v, err := dec.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		// bignums, RFC 8949 section 3.4.3
+		if b, ok := v.([]byte); ok && (arg == 2 || arg == 3) {
+			n := new(big.Int).SetBytes(b)
+			if arg == 3 {
+				n.Neg(n).Sub(n, big.NewInt(1))
+			}
+			if n.IsInt64() {
+				return n.Int64(), nil
+			}
+			f, _ := new(big.Float).SetInt(n).Float64()
+			return f, nil
+		}
+		return v, nil
+	default:
+		return dec.simple(info, arg)
+	}
+}
+
+// str reads the contents of a byte or text string. Strings of indefinite
+// length are concatenated from chunks of the same major type
+func (dec *cborDecoder) str(major byte, arg uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		l, err := dec.length(arg, 1)
+		if err != nil {
+			return nil, err
+		}
+		str := make([]byte, l)
+		copy(str, dec.data[dec.pos:dec.pos+l])
+		dec.pos += l
+		return str, nil
+	}
+
+	str := []byte{}
+	for {
+		if brk, err := dec.atBreak(); err != nil {
+			return nil, err
+		} else if brk {
+			return str, nil
+		}
+		chunkMajor, _, chunkArg, chunkIndefinite, err := dec.head()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || chunkIndefinite {
+			return nil, fmt.Errorf("invalid CBOR: string chunks must be definite strings of the same type")
+		}
+		chunk, err := dec.str(major, chunkArg, false)
+		if err != nil {
+			return nil, err
+		}
+		str = append(str, chunk...)
+	}
+}
+
+func (dec *cborDecoder) array(arg uint64, indefinite bool, depth int) (interface{}, error) {
+	var arr []interface{}
+	if indefinite {
+		arr = []interface{}{}
+	} else {
+		l, err := dec.length(arg, 1)
+		if err != nil {
+			return nil, err
+		}
+		arr = make([]interface{}, 0, l)
+	}
+
+	for i := 0; indefinite || i < cap(arr); i++ {
+		if indefinite {
+			if brk, err := dec.atBreak(); err != nil {
+				return nil, err
+			} else if brk {
+				break
+			}
+		}
+		v, err := dec.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
+
+func (dec *cborDecoder) object(arg uint64, indefinite bool, depth int) (interface{}, error) {
+	l := 0
+	if !indefinite {
+		var err error
+		if l, err = dec.length(arg, 2); err != nil {
+			return nil, err
+		}
+	}
+
+	obj := make(map[string]interface{}, l)
+	for i := 0; indefinite || i < l; i++ {
+		if indefinite {
+			if brk, err := dec.atBreak(); err != nil {
+				return nil, err
+			} else if brk {
+				break
+			}
+		}
+		k, err := dec.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		var key string
+		switch x := k.(type) {
+		case string:
+			key = x
+		case int64:
+			key = fmt.Sprintf("%d", x)
+		default:
+			return nil, fmt.Errorf("invalid CBOR: unsupported map key type %T", k)
+		}
+		if _, ok := obj[key]; ok {
+			return nil, fmt.Errorf("invalid CBOR: duplicate map key %q", key)
+		}
+		if obj[key], err = dec.value(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+// simple reads simple values & floats, RFC 8949 section 3.3
+func (dec *cborDecoder) simple(info byte, arg uint64) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return halfToFloat(uint16(arg)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case 27:
+		return math.Float64frombits(arg), nil
+	}
+	return nil, fmt.Errorf("invalid CBOR: unsupported simple value %d", arg)
+}
+
+// floatToHalf converts f to an IEEE 754 half-precision float, reporting false
+// if f can't be represented exactly. NaNs convert to the canonical quiet NaN
+func floatToHalf(f float64) (uint16, bool) {
+	var sign uint16
+	if math.Signbit(f) {
+		sign = 0x8000
+	}
+	switch abs := math.Abs(f); {
+	case math.IsNaN(f):
+		return 0x7e00, true
+	case math.IsInf(f, 0):
+		return sign | 0x7c00, true
+	case abs == 0:
+		return sign, true
+	case abs < math.Ldexp(1, -14):
+		// subnormals are multiples of 2^-24
+		mant := math.Ldexp(abs, 24)
+		if mant != math.Trunc(mant) {
+			return 0, false
+		}
+		return sign | uint16(mant), true
+	case abs <= 65504:
+		frac, exp := math.Frexp(abs)
+		mant := math.Ldexp(frac*2-1, 10)
+		if mant != math.Trunc(mant) {
+			return 0, false
+		}
+		return sign | uint16(exp+14)<<10 | uint16(mant), true
+	}
+	return 0, false
+}
+
+// halfToFloat converts an IEEE 754 half-precision float
+func halfToFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
+
+// MarshalCBOR encodes deltas as CBOR, in the tuple form of Delta.MarshalJSON.
+// Paths keep their type: object keys are text strings, array indices are
+// integers, and the root is null. Map keys are written in the deterministic
+// order of RFC 8949 section 4.2.1, and floats in the shortest form that keeps
+// their value
+func (ds Deltas) MarshalCBOR() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := writeCBORDeltas(buf, ds); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalCBOR decodes deltas encoded with MarshalCBOR
+func (ds *Deltas) UnmarshalCBOR(data []byte) error {
+	v, err := DecodeCBOR(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	deltas, err := cborDeltas(v)
+	if err != nil {
+		return err
+	}
+	*ds = deltas
+	return nil
+}
+
+func writeCBORDeltas(buf *bytes.Buffer, ds Deltas) error {
+	writeCBORHead(buf, cborArray, uint64(len(ds)))
+	for _, d := range ds {
+		l := 3
+		if len(d.Deltas) > 0 {
+			l++
+		}
+		tr := d.trailer()
+		if tr != nil {
+			l++
+		}
+
+		writeCBORHead(buf, cborArray, uint64(l))
+		writeCBORString(buf, cborText, string(d.Type))
+		if err := writeCBORAddr(buf, d.Path); err != nil {
+			return err
+		}
+		if len(d.Deltas) > 0 {
+			buf.WriteByte(0xf6)
+			if err := writeCBORDeltas(buf, d.Deltas); err != nil {
+				return err
+			}
+		} else if err := writeCBORValue(buf, d.Value); err != nil {
+			return err
+		}
+		if tr != nil {
+			if err := writeCBORValue(buf, tr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeCBORAddr(buf *bytes.Buffer, addr Addr) error {
+	switch a := addr.(type) {
+	case StringAddr:
+		writeCBORString(buf, cborText, string(a))
+	case IndexAddr:
+		writeCBORInt(buf, int64(a))
+	case RootAddr, nil:
+		buf.WriteByte(0xf6)
+	default:
+		return fmt.Errorf("can't encode address type %T as CBOR", addr)
+	}
+	return nil
+}
+
+// writeCBORHead writes the initial byte & argument of a data item in the
+// shortest form
+func writeCBORHead(buf *bytes.Buffer, major byte, arg uint64) {
+	var b [9]byte
+	switch {
+	case arg < 24:
+		buf.WriteByte(major<<5 | byte(arg))
+		return
+	case arg <= math.MaxUint8:
+		b[0], b[1] = major<<5|24, byte(arg)
+		buf.Write(b[:2])
+	case arg <= math.MaxUint16:
+		b[0] = major<<5 | 25
+		binary.BigEndian.PutUint16(b[1:], uint16(arg))
+		buf.Write(b[:3])
+	case arg <= math.MaxUint32:
+		b[0] = major<<5 | 26
+		binary.BigEndian.PutUint32(b[1:], uint32(arg))
+		buf.Write(b[:5])
+	default:
+		b[0] = major<<5 | 27
+		binary.BigEndian.PutUint64(b[1:], arg)
+		buf.Write(b[:9])
+	}
+}
+
+func writeCBORString(buf *bytes.Buffer, major byte, str string) {
+	writeCBORHead(buf, major, uint64(len(str)))
+	buf.WriteString(str)
+}
+
+func writeCBORInt(buf *bytes.Buffer, i int64) {
+	if i < 0 {
+		writeCBORHead(buf, cborNegint, uint64(-1-i))
+		return
+	}
+	writeCBORHead(buf, cborUint, uint64(i))
+}
+
+func writeCBORFloat(buf *bytes.Buffer, f float64) {
+	var b [9]byte
+	if h, ok := floatToHalf(f); ok {
+		b[0] = cborSimple<<5 | 25
+		binary.BigEndian.PutUint16(b[1:], h)
+		buf.Write(b[:3])
+		return
+	}
+	if f32 := float32(f); float64(f32) == f || math.IsNaN(f) {
+		b[0] = cborSimple<<5 | 26
+		binary.BigEndian.PutUint32(b[1:], math.Float32bits(f32))
+		buf.Write(b[:5])
+		return
+	}
+	b[0] = cborSimple<<5 | 27
+	binary.BigEndian.PutUint64(b[1:], math.Float64bits(f))
+	buf.Write(b[:9])
+}
+
+func writeCBORValue(buf *bytes.Buffer, v interface{}) error {
+	switch x := preprocessType(v).(type) {
+	case nil:
+		buf.WriteByte(0xf6)
+	case bool:
+		if x {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case int64:
+		writeCBORInt(buf, x)
+	case float64:
+		writeCBORFloat(buf, x)
+	case string:
+		writeCBORString(buf, cborText, x)
+	case []byte:
+		writeCBORHead(buf, cborBytes, uint64(len(x)))
+		buf.Write(x)
+	case []interface{}:
+		writeCBORHead(buf, cborArray, uint64(len(x)))
+		for _, el := range x {
+			if err := writeCBORValue(buf, el); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		// deterministic encoding sorts keys by their encoded bytes, which for
+		// text strings is shortest first, then bytewise
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+
+		writeCBORHead(buf, cborMap, uint64(len(x)))
+		for _, key := range keys {
+			writeCBORString(buf, cborText, key)
+			if err := writeCBORValue(buf, x[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("can't encode value type %T as CBOR", v)
+	}
+	return nil
+}
+
+// cborDeltas converts a decoded CBOR value to deltas
+func cborDeltas(v interface{}) (Deltas, error) {
+	tuples, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid deltas: expected an array, got %T", v)
+	}
+
+	ds := make(Deltas, 0, len(tuples))
+	for _, t := range tuples {
+		tuple, ok := t.([]interface{})
+		if !ok || len(tuple) < 3 {
+			return nil, fmt.Errorf("invalid delta: expected an array of at least 3 elements")
+		}
+		op, ok := tuple[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid delta: expected operation to be a string, got %T", tuple[0])
+		}
+		d := &Delta{Type: Operation(op)}
+
+		switch p := tuple[1].(type) {
+		case string:
+			d.Path = StringAddr(p)
+		case int64:
+			d.Path = IndexAddr(p)
+		case nil:
+			d.Path = RootAddr{}
+		default:
+			return nil, fmt.Errorf("invalid delta: unexpected path type %T", tuple[1])
+		}
+
+		// a trailing map holds optional fields. It can't be mistaken for a
+		// value, which is always the third element, or for child deltas,
+		// which are an array
+		if len(tuple) > 3 {
+			if tr, ok := tuple[len(tuple)-1].(map[string]interface{}); ok {
+				if err := cborTrailer(d, tr); err != nil {
+					return nil, err
+				}
+				tuple = tuple[:len(tuple)-1]
+			}
+		}
+
+		switch len(tuple) {
+		case 3:
+			d.Value = tuple[2]
+		case 4:
+			children, err := cborDeltas(tuple[3])
+			if err != nil {
+				return nil, err
+			}
+			d.Deltas = children
+		default:
+			return nil, fmt.Errorf("invalid delta: too many elements")
+		}
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+// cborTrailer sets the optional fields of a delta from a decoded trailer
+func cborTrailer(d *Delta, tr map[string]interface{}) error {
+	if v, ok := tr["sourcePath"]; ok {
+		src, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("invalid delta: expected source path to be a string, got %T", v)
+		}
+		d.SourcePath = src
+	}
+	if v, ok := tr["textDiff"]; ok {
+		edits, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid delta: expected text diff to be an array, got %T", v)
+		}
+		d.TextDiff = make(TextDiff, len(edits))
+		for i, e := range edits {
+			edit, ok := e.([]interface{})
+			if !ok || len(edit) != 2 {
+				return fmt.Errorf("invalid delta: expected text edit to be an array of 2 elements")
+			}
+			op, ok := edit[0].(string)
+			if !ok {
+				return fmt.Errorf("invalid delta: expected text edit type to be a string, got %T", edit[0])
+			}
+			text, ok := edit[1].(string)
+			if !ok {
+				return fmt.Errorf("invalid delta: expected text edit text to be a string, got %T", edit[1])
+			}
+			d.TextDiff[i] = TextEdit{Type: Operation(op), Text: text}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package deepdiff
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	// examples from RFC 8949 appendix A
+	cases := []struct {
+		hex    string
+		expect interface{}
+	}{
+		{"00", int64(0)},
+		{"1818", int64(24)},
+		{"1b000000e8d4a51000", int64(1000000000000)},
+		{"1bffffffffffffffff", float64(18446744073709551615)},
+		{"3863", int64(-100)},
+		{"3bffffffffffffffff", float64(-18446744073709551616)},
+		{"c249010000000000000000", float64(18446744073709551616)},
+		{"c34101", int64(-2)},
+		{"f93c00", float64(1)},
+		{"f9c400", float64(-4)},
+		{"f90001", 5.960464477539063e-8},
+		{"f97c00", math.Inf(1)},
+		{"fa47c35000", float64(100000)},
+		{"fb3ff199999999999a", 1.1},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"f7", nil},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"62c3bc", "ü"},
+		{"83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"a201020304", map[string]interface{}{"1": int64(2), "3": int64(4)}},
+		{"a26161016162820203", map[string]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"9fff", []interface{}{}},
+		{"9f018202039f0405ffff", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"bf61610161629f0203ffff", map[string]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"c074323031332d30332d32315432303a30343a30305a", "2013-03-21T20:04:00Z"},
+	}
+
+	for _, c := range cases {
+		data, err := hex.DecodeString(c.hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecodeCBOR(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: %s", c.hex, err)
+			continue
+		}
+		if diff := cmp.Diff(c.expect, got); diff != "" {
+			t.Errorf("%s: result mismatch (-want +got):\n%s", c.hex, diff)
+		}
+	}
+}
+
+func TestDecodeCBORErrors(t *testing.T) {
+	cases := []string{
+		"",               // no data
+		"0000",           // trailing data
+		"1c",             // reserved additional info
+		"ff",             // unexpected break
+		"f0",             // unassigned simple value
+		"830102",         // array too short
+		"5a00010000",     // byte string longer than data
+		"62c328",         // invalid UTF-8
+		"a1f4f4",         // unsupported key type
+		"a2616101616102", // duplicate key
+		"5f6161ff",       // text chunk in a byte string
+	}
+
+	for _, c := range cases {
+		data, err := hex.DecodeString(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := DecodeCBOR(bytes.NewReader(data)); err == nil {
+			t.Errorf("%q: expected error, got nil", c)
+		}
+	}
+
+	deep := bytes.Repeat([]byte{0x81}, cborMaxDepth+2)
+	if _, err := DecodeCBOR(bytes.NewReader(append(deep, 0))); err == nil {
+		t.Error("expected error exceeding max depth")
+	}
+}
+
+func TestDiffCBOR(t *testing.T) {
+	// {"id": 4294967296, "sig": h'0102'}
+	a, err := hex.DecodeString("a2" + "626964" + "1b0000000100000000" + "63736967" + "420102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// {"id": 4294967296, "sig": h'0103'}
+	b, err := hex.DecodeString("a2" + "626964" + "1b0000000100000000" + "63736967" + "420103")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := New(func(cfg *Config) { cfg.CalcChanges = true }).DiffCBOR(context.Background(), bytes.NewReader(a), bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Deltas{
+		{Type: DTContext, Path: StringAddr("id"), Value: int64(4294967296)},
+		{Type: DTUpdate, Path: StringAddr("sig"), Value: []byte{1, 3}, SourceValue: []byte{1, 2}},
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+
+	src, err := DecodeCBOR(bytes.NewReader(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := DecodeCBOR(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Patch(got, &src); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(dst, src); diff != "" {
+		t.Errorf("patched result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDeltasCBOR(t *testing.T) {
+	ds := Deltas{
+		{Type: DTDelete, Path: RootAddr{}, Value: nil},
+		{Type: DTContext, Path: StringAddr("a"), Deltas: Deltas{
+			{Type: DTInsert, Path: IndexAddr(0), Value: []interface{}{"a", int64(-7), 1.5, 0.1, true, []byte{0xff}}},
+			{Type: DTRename, Path: StringAddr("to"), SourcePath: "from", Value: map[string]interface{}{"bb": nil, "a": int64(1000)}},
+		}},
+		{Type: DTRename, Path: StringAddr("c"), SourcePath: "d", Deltas: Deltas{
+			{Type: DTUpdate, Path: IndexAddr(3), Value: "x"},
+		}},
+		{Type: DTUpdate, Path: StringAddr("e"), Value: "the slow fox", TextDiff: TextDiff{
+			{Type: DTContext, Text: "the "},
+			{Type: DTDelete, Text: "quick"},
+			{Type: DTInsert, Text: "slow"},
+			{Type: DTContext, Text: " fox"},
+		}},
+	}
+
+	data, err := ds.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonData, err := json.Marshal(ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) >= len(jsonData) {
+		t.Errorf("expected CBOR encoding to be smaller than JSON. cbor: %d bytes, json: %d bytes", len(data), len(jsonData))
+	}
+
+	got := Deltas{}
+	if err := got.UnmarshalCBOR(data); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(ds, got); diff != "" {
+		t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+	}
+
+	// map keys are written shortest first, floats in their shortest form
+	data, err = (Deltas{{Type: DTInsert, Path: IndexAddr(-1), Value: map[string]interface{}{"bb": 0.5, "c": nil, "a": int64(1000)}}}).MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "81" + "83" + "612b" + "20" + "a3" + "6161" + "1903e8" + "6163" + "f6" + "626262" + "f93800"
+	if got := hex.EncodeToString(data); got != expect {
+		t.Errorf("encoding mismatch.\nwant: %s\ngot:  %s", expect, got)
+	}
+
+	floats := []struct {
+		f      float64
+		expect string
+	}{
+		{1.5, "f93e00"},
+		{0, "f90000"},
+		{math.Copysign(0, -1), "f98000"},
+		{math.Inf(1), "f97c00"},
+		{math.NaN(), "f97e00"},
+		{math.Ldexp(1, -24), "f90001"},
+		{65504, "f97bff"},
+		{65505, "fa477fe100"},
+		{1e10, "fa501502f9"},
+		{0.1, "fb3fb999999999999a"},
+	}
+	for _, c := range floats {
+		buf := &bytes.Buffer{}
+		writeCBORFloat(buf, c.f)
+		if got := hex.EncodeToString(buf.Bytes()); got != c.expect {
+			t.Errorf("float %v encoding mismatch. want: %s, got: %s", c.f, c.expect, got)
+		}
+	}
+
+	if _, err := (Deltas{{Type: DTInsert, Path: StringAddr("a"), Value: struct{}{}}}).MarshalCBOR(); err == nil {
+		t.Error("expected error encoding unsupported value type")
+	}
+	if err := got.UnmarshalCBOR([]byte{0x81, 0x01}); err == nil {
+		t.Error("expected error decoding invalid deltas")
+	}
+}
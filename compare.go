@@ -0,0 +1,94 @@
+package deepdiff
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Comparator reduces a scalar value to a canonical key. Two values with equal
+// keys are equal, and the key stands in for the value when hashing, which
+// keeps matching consistent with the deltas a diff reports. Comparators
+// return ok == false for values they don't apply to, which are compared as
+// usual
+type Comparator func(v interface{}) (key string, ok bool)
+
+// StringComparator creates a comparator for string values that compares the
+// result of calling canonical on each string. eg: StringComparator(strings.ToLower)
+// compares strings case-insensitively
+func StringComparator(canonical func(string) string) Comparator {
+	return func(v interface{}) (string, bool) {
+		str, ok := v.(string)
+		if !ok {
+			return "", false
+		}
+		return canonical(str), true
+	}
+}
+
+// CaseInsensitive compares strings without regard to case
+var CaseInsensitive = StringComparator(strings.ToLower)
+
+// TimeComparator creates a comparator that parses strings as timestamps using
+// each layout in turn, comparing the parsed instants. Equal instants written
+// in different formats or time zones are equal. With no layouts, RFC 3339 is
+// used. Strings that don't parse are compared as usual
+func TimeComparator(layouts ...string) Comparator {
+	if len(layouts) == 0 {
+		layouts = []string{time.RFC3339Nano}
+	}
+	return func(v interface{}) (string, bool) {
+		str, ok := v.(string)
+		if !ok {
+			return "", false
+		}
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, str); err == nil {
+				return t.UTC().Format(time.RFC3339Nano), true
+			}
+		}
+		return "", false
+	}
+}
+
+// URLComparator compares strings as URLs after normalizing them. Schemes &
+// hosts are compared case-insensitively, default ports are dropped, and query
+// parameters are sorted. Strings that don't parse as absolute URLs are compared
+// as usual
+var URLComparator Comparator = func(v interface{}) (string, bool) {
+	str, ok := v.(string)
+	if !ok {
+		return "", false
+	}
+	u, err := url.Parse(str)
+	if err != nil || !u.IsAbs() {
+		return "", false
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = u.Hostname()
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawQuery = u.Query().Encode()
+	return u.String(), true
+}
+
+// applyComparator sets the comparison key of a scalar node if a configured
+// comparator applies to it
+func (d *diff) applyComparator(s *scalar, a *arena) {
+	pattern, ok := d.comparatorPatterns.match(path(s))
+	if !ok {
+		return
+	}
+	key, ok := d.comparators[pattern](s.value)
+	if !ok {
+		return
+	}
+	s.key = key
+	s.keyed = true
+	s.hash = hashScalar(a, ntUnknown, key)
+}
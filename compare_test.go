@@ -0,0 +1,55 @@
+package deepdiff
+
+import "testing"
+
+func TestComparators(t *testing.T) {
+	cases := []TestCase{
+		{
+			"equal instants in different formats",
+			`{"id":1,"createdAt":"2019-02-22T14:21:27Z","meta":{"updatedAt":"2019-02-22T15:00:00+01:00"}}`,
+			`{"id":1,"createdAt":"2019-02-22T09:21:27-05:00","meta":{"updatedAt":"2019-02-22T14:00:00Z"}}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("createdAt"), Value: "2019-02-22T09:21:27-05:00"},
+				{Type: DTContext, Path: StringAddr("id"), Value: float64(1)},
+				{Type: DTContext, Path: StringAddr("meta"), Value: map[string]interface{}{"updatedAt": "2019-02-22T14:00:00Z"}},
+			},
+		},
+		{
+			"different instants",
+			`{"id":1,"createdAt":"2019-02-22T14:21:27Z"}`,
+			`{"id":1,"createdAt":"2019-02-22T14:21:28Z"}`,
+			Deltas{
+				{Type: DTUpdate, Path: StringAddr("createdAt"), SourceValue: "2019-02-22T14:21:27Z", Value: "2019-02-22T14:21:28Z"},
+				{Type: DTContext, Path: StringAddr("id"), Value: float64(1)},
+			},
+		},
+		{
+			"case insensitive enum",
+			`{"id":1,"status":"ACTIVE","level":"HIGH"}`,
+			`{"id":1,"status":"active","level":"high"}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("id"), Value: float64(1)},
+				{Type: DTUpdate, Path: StringAddr("level"), SourceValue: "HIGH", Value: "high"},
+				{Type: DTContext, Path: StringAddr("status"), Value: "active"},
+			},
+		},
+		{
+			"normalized urls",
+			`{"id":1,"links":["HTTP://Example.com:80/a?b=1&a=2","https://example.com/"]}`,
+			`{"id":1,"links":["http://example.com/a?a=2&b=1","https://example.com"]}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("id"), Value: float64(1)},
+				{Type: DTContext, Path: StringAddr("links"), Value: []interface{}{"http://example.com/a?a=2&b=1", "https://example.com"}},
+			},
+		},
+	}
+
+	RunDeltaTestCases(t, cases, func(c *Config) {
+		c.CalcChanges = true
+		c.Comparators = map[string]Comparator{
+			"/**/*At":  TimeComparator(),
+			"/status":  CaseInsensitive,
+			"/links/*": URLComparator,
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package deepdiff
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CSVConfig are any possible configuration parameters for decoding CSV
+type CSVConfig struct {
+	// Delimiter separates fields in a record. Defaults to ','
+	Delimiter rune
+	// Comment starts lines that are ignored, 0 disables comments
+	Comment rune
+	// LazyQuotes allows quotes in unquoted fields & unescaped quotes in
+	// quoted fields
+	LazyQuotes bool
+	// Header reads the first record as column names. Header names are never
+	// converted by InferTypes
+	Header bool
+	// RowsAsObjects decodes each record as an object keyed by column name
+	// instead of an array. Requires Header, records can't have more fields than
+	// the header has names
+	RowsAsObjects bool
+	// InferTypes converts fields that read as integers, floats, or booleans
+	// to int64, float64, or bool values. Empty fields become null. Numbers
+	// with leading zeros, like zip codes, stay strings
+	InferTypes bool
+}
+
+// CSVOption is a function that adjusts a CSV config, zero or more CSVOptions
+// can be passed to CSV functions
+type CSVOption func(cfg *CSVConfig)
+
+// DecodeCSV reads a CSV document as an array of records. Records are arrays
+// of fields, or objects when RowsAsObjects is set. A header read as an array
+// stays the first record, ready for diffing with Config.TableHeader
+func DecodeCSV(r io.Reader, opts ...CSVOption) ([]interface{}, error) {
+	cfg := &CSVConfig{Delimiter: ','}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.RowsAsObjects && !cfg.Header {
+		return nil, fmt.Errorf("decoding rows as objects requires a header")
+	}
+
+	rdr := csv.NewReader(r)
+	rdr.Comma = cfg.Delimiter
+	rdr.Comment = cfg.Comment
+	rdr.LazyQuotes = cfg.LazyQuotes
+	rdr.FieldsPerRecord = -1
+	rdr.ReuseRecord = true
+
+	var (
+		rows   = []interface{}{}
+		header []string
+	)
+	for n := 1; ; n++ {
+		rec, err := rdr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.Header && header == nil {
+			header = make([]string, len(rec))
+			copy(header, rec)
+			if !cfg.RowsAsObjects {
+				row := make([]interface{}, len(header))
+				for i, name := range header {
+					row[i] = name
+				}
+				rows = append(rows, row)
+			}
+			continue
+		}
+
+		if cfg.RowsAsObjects {
+			if len(rec) > len(header) {
+				return nil, fmt.Errorf("record %d has %d fields, header has %d", n, len(rec), len(header))
+			}
+			row := make(map[string]interface{}, len(rec))
+			for i, field := range rec {
+				row[header[i]] = csvValue(field, cfg.InferTypes)
+			}
+			rows = append(rows, row)
+			continue
+		}
+
+		row := make([]interface{}, len(rec))
+		for i, field := range rec {
+			row[i] = csvValue(field, cfg.InferTypes)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// csvValue converts a field to a value, inferring the type of the field if
+// infer is true
+func csvValue(field string, infer bool) interface{} {
+	if !infer {
+		return field
+	}
+	if field == "" {
+		return nil
+	}
+	if leadingZero(field) {
+		return field
+	}
+	if i, err := strconv.ParseInt(field, 10, 64); err == nil {
+		return i
+	}
+	// ParseFloat reads words like "inf" & "nan" that are better left as strings
+	if f, err := strconv.ParseFloat(field, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f
+	}
+	if strings.EqualFold(field, "true") {
+		return true
+	}
+	if strings.EqualFold(field, "false") {
+		return false
+	}
+	return field
+}
+
+// leadingZero returns true if a field starts with a zero followed by another
+// digit, ignoring any sign
+func leadingZero(field string) bool {
+	if field[0] == '-' || field[0] == '+' {
+		field = field[1:]
+	}
+	return len(field) > 1 && field[0] == '0' && field[1] >= '0' && field[1] <= '9'
+}
+
+// DiffCSV computes deltas between two CSV documents, decoding both with the
+// given options
+func (dd *DeepDiff) DiffCSV(ctx context.Context, r1, r2 io.Reader, opts ...CSVOption) (Deltas, error) {
+	a, err := DecodeCSV(r1, opts...)
+	if err != nil {
+		return nil, err
+	}
+	b, err := DecodeCSV(r2, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return dd.Diff(ctx, a, b)
+}
@@ -0,0 +1,132 @@
+package deepdiff
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecodeCSV(t *testing.T) {
+	header := func(cfg *CSVConfig) { cfg.Header = true }
+	infer := func(cfg *CSVConfig) { cfg.InferTypes = true }
+
+	cases := []struct {
+		description string
+		input       string
+		opts        []CSVOption
+		expect      []interface{}
+	}{
+		{"strings",
+			"a,1\nb,2\n",
+			nil,
+			[]interface{}{
+				[]interface{}{"a", "1"},
+				[]interface{}{"b", "2"},
+			},
+		},
+		{"inferred types",
+			"a,1,-2.5,true,FALSE,,007,nan\n",
+			[]CSVOption{infer},
+			[]interface{}{
+				[]interface{}{"a", int64(1), float64(-2.5), true, false, nil, "007", "nan"},
+			},
+		},
+		{"header stays a row of strings",
+			"1,true\n2,false\n",
+			[]CSVOption{header, infer},
+			[]interface{}{
+				[]interface{}{"1", "true"},
+				[]interface{}{int64(2), false},
+			},
+		},
+		{"rows as objects",
+			"id,name\n1,a\n2\n",
+			[]CSVOption{header, infer, func(cfg *CSVConfig) { cfg.RowsAsObjects = true }},
+			[]interface{}{
+				map[string]interface{}{"id": int64(1), "name": "a"},
+				map[string]interface{}{"id": int64(2)},
+			},
+		},
+		{"delimiter & comments",
+			"# comment\na;b\nc;\"d;e\"\n",
+			[]CSVOption{func(cfg *CSVConfig) {
+				cfg.Delimiter = ';'
+				cfg.Comment = '#'
+			}},
+			[]interface{}{
+				[]interface{}{"a", "b"},
+				[]interface{}{"c", "d;e"},
+			},
+		},
+		{"empty",
+			"",
+			[]CSVOption{header},
+			[]interface{}{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			got, err := DecodeCSV(strings.NewReader(c.input), c.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.expect, got); diff != "" {
+				t.Errorf("result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDecodeCSVErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		opts  []CSVOption
+	}{
+		{"a,\"b\n", nil},
+		{"a\nb\n", []CSVOption{func(cfg *CSVConfig) { cfg.RowsAsObjects = true }}},
+		{"a\nb,c\n", []CSVOption{func(cfg *CSVConfig) {
+			cfg.Header = true
+			cfg.RowsAsObjects = true
+		}}},
+	}
+
+	for i, c := range cases {
+		if _, err := DecodeCSV(strings.NewReader(c.input), c.opts...); err == nil {
+			t.Errorf("case %d: expected error, got nil", i)
+		}
+	}
+}
+
+func TestDiffCSV(t *testing.T) {
+	a := "id,name,age\n1,a,10\n2,b,20\n"
+	b := "id,name,age\n1,a,11\n2,b,20\n"
+
+	got, err := New().DiffCSV(context.Background(), strings.NewReader(a), strings.NewReader(b), func(cfg *CSVConfig) {
+		cfg.Header = true
+		cfg.InferTypes = true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := Deltas{
+		{Type: DTContext, Path: IndexAddr(0), Value: []interface{}{"id", "name", "age"}},
+		{Type: DTContext, Path: IndexAddr(1), Deltas: Deltas{
+			{Type: DTContext, Path: IndexAddr(0), Value: int64(1)},
+			{Type: DTContext, Path: IndexAddr(1), Value: "a"},
+			{Type: DTDelete, Path: IndexAddr(2), Value: int64(10)},
+			{Type: DTInsert, Path: IndexAddr(2), Value: int64(11)},
+		}},
+		{Type: DTContext, Path: IndexAddr(2), Value: []interface{}{int64(2), "b", int64(20)}},
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := New().DiffCSV(context.Background(), strings.NewReader("\""), strings.NewReader(b)); err == nil {
+		t.Error("expected error decoding invalid CSV")
+	}
+}
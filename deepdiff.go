@@ -6,7 +6,9 @@ import (
 	"hash"
 	"hash/fnv"
 	"reflect"
+	"runtime"
 	"sort"
+	"sync"
 )
 
 // Config are any possible configuration parameters for calculating diffs
@@ -14,6 +16,90 @@ type Config struct {
 	// Setting CalcChanges to true will have diff represent in-place value shifts
 	// as changes instead of add-delete pairs
 	CalcChanges bool
+	// TextDiffThreshold is the length in bytes a string must reach before an
+	// update to that string carries a text diff. 0 disables text diffs.
+	// Strings that differ in more than 1024 tokens are too costly to diff, and
+	// are reported as plain updates
+	TextDiffThreshold int
+	// TextDiffMode sets how strings are split into tokens for text diffs
+	TextDiffMode TextDiffMode
+	// ArrayModes sets how arrays at matching paths are compared, keyed by path
+	// pattern. Patterns are slash-delimited addresses where "*" matches any
+	// single address & "**" matches any number of addresses, eg: "/tags" or
+	// "/users/*/roles". When more than one pattern matches a path, the pattern
+	// with the most literal addresses wins. Unmatched arrays are ordered
+	ArrayModes map[string]ArrayMode
+	// NullAsMissing, EmptyArrayAsMissing, and EmptyObjectAsMissing treat object
+	// keys set to null, [], or {} as if the key were missing, which also makes
+	// these values equivalent to each other. Objects are empty if all their
+	// values are treated as missing. Array elements can't be missing, so they
+	// compare as null instead
+	NullAsMissing        bool
+	EmptyArrayAsMissing  bool
+	EmptyObjectAsMissing bool
+	// Comparators replace the default comparison of scalar values at matching
+	// paths, keyed by path pattern. Patterns follow the same rules as
+	// ArrayModes, use "/**" to apply a comparator to every value
+	Comparators map[string]Comparator
+	// Transforms normalize values before they're hashed & diffed, running in
+	// order on every value at a matching path. Deltas describe the normalized
+	// values unless KeepRawValues is set
+	Transforms []TransformRule
+	// KeepRawValues reports values as given in delta Values & SourceValues,
+	// instead of their normalized form
+	KeepRawValues bool
+	// NewHash sets the hash function used to fingerprint subtrees for this
+	// diff. eg: fnv.New128a or sha256.New for a larger value space. Defaults
+	// to the package-level NewHash
+	NewHash func() hash.Hash
+	// VerifyMatches confirms subtrees with equal hashes are structurally equal
+	// before matching them, guarding against hash collisions at the cost of
+	// comparing candidate values
+	VerifyMatches bool
+	// Workers bounds the number of goroutines used to match subtrees.
+	// Defaults to GOMAXPROCS
+	Workers int
+	// ParallelThreshold is the number of elements an array or object needs
+	// before its children are built & hashed in parallel. Defaults to 1024
+	ParallelThreshold int
+	// SimilarityThreshold enables fuzzy matching of arrays & objects that
+	// aren't identical, but share most of their children. Similarity scores
+	// range from 0 to 1, pairs scoring at or above the threshold are matched &
+	// diffed as nested changes instead of a delete & insert. 0 disables fuzzy
+	// matching. The threshold also sets how similar arrays & objects in
+	// aligned arrays need to be to diff in place, which defaults to 0.5
+	SimilarityThreshold float64
+	// TopDown matches trees with a top-down mapping instead of matching
+	// subtrees heuristically: nodes only match when their parents match, object
+	// values only match values of the same key, and array elements match in
+	// order. Values moved to a new parent are a delete & an insert. This is not
+	// a tree edit distance. Top-down diffs take time proportional to the
+	// product of document sizes, so they're best kept to small documents
+	TopDown bool
+	// TopDownThreshold diffs documents in top-down mode when they have fewer
+	// nodes than the threshold combined. 0 never switches to top-down mode
+	TopDownThreshold int
+	// MaxOptimizePasses caps the number of passes spent propagating matches
+	// between parents & children. Passes stop early once a pass adds no new
+	// matches. Defaults to 32
+	MaxOptimizePasses int
+	// RenameThreshold enables detection of renamed object keys. A key that
+	// only exists in A is reported as renamed to a key that only exists in B
+	// when their values score at or above the threshold for similarity, 1
+	// renames identical values only. 0 disables rename detection
+	RenameThreshold float64
+	// Tabular diffs tables, arrays where every element is an array of cells,
+	// column by column: inserted, deleted, renamed & moved columns are
+	// matched once for the table instead of in every row. Only applies when
+	// both documents are tables. See DiffTable for a description of changes
+	// by row & column
+	Tabular bool
+	// TableHeader reads the first row of tables as column names
+	TableHeader bool
+	// TableKey names a column that identifies rows. Rows with equal keys are
+	// matched instead of aligning rows by position. Columns of tables without
+	// a header are named by index, eg: "0"
+	TableKey string
 }
 
 // DiffOption is a function that adjust a config, zero or more DiffOptions
@@ -22,7 +108,36 @@ type DiffOption func(cfg *Config)
 
 // DeepDiff is a configuration for performing diffs
 type DeepDiff struct {
-	changes bool
+	changes       bool
+	textThreshold int
+	textMode      TextDiffMode
+	arrayModes    map[string]ArrayMode
+	arrayPatterns pathPatterns
+
+	nullAsMissing        bool
+	emptyArrayAsMissing  bool
+	emptyObjectAsMissing bool
+
+	comparators        map[string]Comparator
+	comparatorPatterns pathPatterns
+
+	transforms    []transformRule
+	keepRawValues bool
+
+	newHash       func() hash.Hash
+	verifyMatches bool
+	workers       int
+
+	parallelThreshold   int
+	similarityThreshold float64
+	topDown             bool
+	topDownThreshold    int
+	maxOptimizePasses   int
+	renameThreshold     float64
+
+	tabular     bool
+	tableHeader bool
+	tableKey    string
 }
 
 // New creates a deepdiff struct
@@ -32,9 +147,67 @@ func New(opts ...DiffOption) *DeepDiff {
 		opt(cfg)
 	}
 
-	return &DeepDiff{
-		changes: cfg.CalcChanges,
+	dd := &DeepDiff{
+		changes:       cfg.CalcChanges,
+		textThreshold: cfg.TextDiffThreshold,
+		textMode:      cfg.TextDiffMode,
+		arrayModes:    map[string]ArrayMode{},
+
+		nullAsMissing:        cfg.NullAsMissing,
+		emptyArrayAsMissing:  cfg.EmptyArrayAsMissing,
+		emptyObjectAsMissing: cfg.EmptyObjectAsMissing,
+	}
+
+	var arrayPatterns []string
+	for pattern, mode := range cfg.ArrayModes {
+		dd.arrayModes[pattern] = mode
+		arrayPatterns = append(arrayPatterns, pattern)
+	}
+	dd.arrayPatterns = newPathPatterns(arrayPatterns)
+
+	dd.comparators = map[string]Comparator{}
+	var comparatorPatterns []string
+	for pattern, cmp := range cfg.Comparators {
+		dd.comparators[pattern] = cmp
+		comparatorPatterns = append(comparatorPatterns, pattern)
+	}
+	dd.comparatorPatterns = newPathPatterns(comparatorPatterns)
+
+	dd.transforms = newTransformRules(cfg.Transforms)
+	dd.keepRawValues = cfg.KeepRawValues
+
+	dd.newHash = cfg.NewHash
+	if dd.newHash == nil {
+		dd.newHash = NewHash
+	}
+	dd.verifyMatches = cfg.VerifyMatches
+
+	dd.workers = cfg.Workers
+	if dd.workers <= 0 {
+		dd.workers = runtime.GOMAXPROCS(0)
+	}
+	dd.parallelThreshold = cfg.ParallelThreshold
+	if dd.parallelThreshold <= 0 {
+		dd.parallelThreshold = 1024
+	}
+	dd.similarityThreshold = cfg.SimilarityThreshold
+	dd.topDown = cfg.TopDown
+	dd.topDownThreshold = cfg.TopDownThreshold
+	dd.maxOptimizePasses = cfg.MaxOptimizePasses
+	if dd.maxOptimizePasses <= 0 {
+		dd.maxOptimizePasses = 32
 	}
+	dd.renameThreshold = cfg.RenameThreshold
+	dd.tabular = cfg.Tabular
+	dd.tableHeader = cfg.TableHeader
+	dd.tableKey = cfg.TableKey
+
+	return dd
+}
+
+// newDiff creates diff state for a single diff of a & b
+func (dd *DeepDiff) newDiff(a, b interface{}) *diff {
+	return &diff{DeepDiff: dd, d1: a, d2: b}
 }
 
 // Diff computes a slice of deltas that define an edit script for turning a
@@ -43,19 +216,21 @@ func New(opts ...DiffOption) *DeepDiff {
 // future use. specifically: bailing before delta calculation based on a
 // configurable threshold
 func (dd *DeepDiff) Diff(ctx context.Context, a, b interface{}) (Deltas, error) {
-	deepdiff := &diff{changes: dd.changes, d1: a, d2: b}
+	deepdiff := dd.newDiff(a, b)
 	return deepdiff.diff(ctx), nil
 }
 
 // StatDiff calculates a diff script and diff stats
 func (dd *DeepDiff) StatDiff(ctx context.Context, a, b interface{}) (Deltas, *Stats, error) {
-	deepdiff := &diff{changes: dd.changes, d1: a, d2: b, stats: &Stats{}}
+	deepdiff := dd.newDiff(a, b)
+	deepdiff.stats = &Stats{}
 	return deepdiff.diff(ctx), deepdiff.stats, nil
 }
 
 // Stat calculates the DiffStats between two documents
 func (dd *DeepDiff) Stat(ctx context.Context, a, b interface{}) (*Stats, error) {
-	deepdiff := &diff{changes: dd.changes, d1: a, d2: b, stats: &Stats{}}
+	deepdiff := dd.newDiff(a, b)
+	deepdiff.stats = &Stats{}
 	deepdiff.diff(ctx)
 	return deepdiff.stats, nil
 }
@@ -63,54 +238,90 @@ func (dd *DeepDiff) Stat(ctx context.Context, a, b interface{}) (*Stats, error)
 // diff is a state machine for calculating an edit script that transitions
 // between two state trees
 type diff struct {
-	changes bool // calculate changes flag
-	stats   *Stats
-	d1, d2  interface{}
-	t1, t2  node
-	t1Nodes map[string][]node
+	*DeepDiff // diff configuration
+	stats     *Stats
+	d1, d2    interface{}
+	prepared  *Prepared
+	right     *builtTree
+	t1, t2    node
+	t1Nodes   map[string][]node
+
+	// per-diff node state, indexed by node index
+	matches     []node
+	changeTypes []Operation
+	addrs       []Addr
+	// delete addresses of array elements, calculated by deleteIndex
+	deleteAddrs map[*array][]int
+	// buffers reused by queueMatch for candidate lookups & climbing candidates
+	found    [][]node
+	climbing []node
+
+	// bounds the number of goroutines building trees
+	sem chan struct{}
 }
 
 // diff calculates a structl diff for two given tree states
 // generating an edit script as a list of Delta changes:
 //
-// 1. prepTrees - register in a map a unique signature (hash value) for every
-//    subtree of the d1 (old) document
-// 2. queueMatch - consider every subtree in d2 document, starting from the
-//    largest. check if it is identitical to some the subtrees in
-//    d1, if so match both subtrees.
-// 3. attempt to match the parents of two matched subtrees
-//    by checking labels (in our case, types of parent object or array)
-//    controlling for bad matches based on length of path to the
-//    ancestor and the weight of the matching subtrees. eg: a large
-//    subtree may force the matching of its ancestors up to the root
-//    a small subtree may not even force matching of its parent
-// 4. Consider the largest subtrees of d2 in order. If one candidate
-//    has it's parent already matched to the parent of the considered
-//    node, it is certianly the best candidate.
-// 5. At this point we might have matched all of d2. A node may not
-//    match b/c its been inserted, or we missed matching it. We can now
-//    do peephole optimization pass to retry some of the rejected nodes
-//    once no more matchings can be obtained, unmatched nodes in d2
-//    correspond to inserted nodes.
-// 6. consider each matching node and decide if the node is at its right
-//    place, or whether it has been moved.
+//  1. prepTrees - register in a map a unique signature (hash value) for every
+//     subtree of the d1 (old) document
+//  2. queueMatch - consider every subtree in d2 document, starting from the
+//     largest. check if it is identitical to some the subtrees in
+//     d1, if so match both subtrees.
+//  3. attempt to match the parents of two matched subtrees
+//     by checking labels (in our case, types of parent object or array)
+//     controlling for bad matches based on length of path to the
+//     ancestor and the weight of the matching subtrees. eg: a large
+//     subtree may force the matching of its ancestors up to the root
+//     a small subtree may not even force matching of its parent
+//  4. Consider the largest subtrees of d2 in order. If one candidate
+//     has it's parent already matched to the parent of the considered
+//     node, it is certianly the best candidate.
+//  5. At this point we might have matched all of d2. A node may not
+//     match b/c its been inserted, or we missed matching it. We can now
+//     do peephole optimization pass to retry some of the rejected nodes
+//     once no more matchings can be obtained, unmatched nodes in d2
+//     correspond to inserted nodes.
+//  6. if a similarity threshold is set, pair remaining unmatched arrays &
+//     objects in the same position that share enough of their children
+//  7. consider each matching node and decide if the node is at its right
+//     place, or whether it has been moved.
+//  8. if a rename threshold is set, pair keys deleted from & inserted into
+//     matched objects that hold identical or similar values as renames
+//
+// top-down diffs replace steps 2-7 with a mapping from topDownMatch, and
+// tabular diffs of two tables replace steps 2-8 with a mapping from tableMatch
 func (d *diff) diff(ctx context.Context) Deltas {
 	d.t1, d.t2, d.t1Nodes = d.prepTrees(ctx)
-	d.queueMatch(d.t1Nodes, d.t2)
-	d.optimize(d.t1, d.t2)
-	// TODO (b5): a second optimize pass seems to help on larger diffs, which
-	// to me seems we should propagating matches more aggressively in the optimize pass,
-	// removing the need for this second call (which is effectively doing the same
-	// thing as recursive/aggressive match propagation)
-	d.optimize(d.t1, d.t2)
-	d.optimize(d.t1, d.t2)
+	if d.tabular {
+		t1, ok1 := table(d.t1)
+		t2, ok2 := table(d.t2)
+		if ok1 && ok2 {
+			if _, err := d.tableMatch(t1, t2); err == nil {
+				return d.calcDeltas(d.t1, d.t2)
+			}
+		}
+	}
+	if d.topDown || len(d.matches) < d.topDownThreshold {
+		d.topDownMatch(d.t1, d.t2)
+	} else {
+		d.queueMatch(d.t1Nodes, d.t2)
+		d.optimizeFixpoint(d.t1, d.t2)
+		if d.similarityThreshold > 0 {
+			d.fuzzyMatch(d.t2)
+		}
+		d.dropMovedMatches(d.t1, d.t2)
+	}
+	if d.renameThreshold > 0 {
+		d.matchRenames(d.t2)
+	}
 	return d.calcDeltas(d.t1, d.t2)
 }
 
 // NewHash returns a new hash interface, wrapped in a function for easy
-// hash algorithm switching, package consumers can override NewHash
-// with their own desired hash.Hash implementation if the value space is
-// particularly large. default is 64-bit FNV 1 for fast, cheap,
+// hash algorithm switching. NewHash is the default for diffs that don't set
+// Config.NewHash, prefer setting a hash function per-diff, which doesn't
+// affect other diffs in the process. default is 64-bit FNV 1 for fast, cheap,
 // (non-cryptographic) hashing
 var NewHash = func() hash.Hash {
 	return fnv.New64()
@@ -122,78 +333,149 @@ func hashStr(sum []byte) string {
 	return hex.EncodeToString(sum)
 }
 
+// queueMatch considers t2 subtrees breadth-first, one level at a time. Each
+// level is a batch: candidate lookups for a batch are split across a bounded
+// pool of workers, then matches are applied in order. Children of nodes
+// without candidates form the next batch
 func (d *diff) queueMatch(t1Nodes map[string][]node, t2 node) {
-	queue := make(chan node)
-	done := make(chan struct{})
-	considering := 1
 	t2Weight := t2.Weight()
+	batch, next := []node{t2}, []node(nil)
 
-	go func() {
-		var candidates []node
-		for n2 := range queue {
-			key := hashStr(n2.Hash())
+	for len(batch) > 0 {
+		found := d.findCandidates(t1Nodes, batch)
 
-			candidates = t1Nodes[key]
+		next = next[:0]
+		for i, n2 := range batch {
+			candidates := d.unmatchedCandidates(found[i])
 			switch len(candidates) {
 			case 0:
 				// no candidates. check if node has children. If so, add them.
 				if n2c, ok := n2.(compound); ok {
-					for _, ch := range n2c.Children() {
-						considering++
-						go func(n node) {
-							queue <- n
-						}(ch)
-					}
+					next = append(next, n2c.Children()...)
 				}
 			case 1:
 				// connect an exact match. yay!
 				n1 := candidates[0]
-				matchNodes(n1, n2)
+				d.matchNodes(n1, n2)
 			default:
 				// choose a best candidate. let the sketchiness begin.
-				bestCandidate(candidates, n2, t2Weight)
+				d.bestCandidate(candidates, n2, t2Weight)
+			}
+		}
+		// swap batches, reusing the last batch for the level after next
+		batch, next = next, batch
+	}
+}
+
+// minWorkerBatch is the smallest batch worth splitting across workers
+const minWorkerBatch = 256
+
+// findCandidates looks up t1 candidates for every node in a batch. The
+// returned slice is reused by the next batch
+func (d *diff) findCandidates(t1Nodes map[string][]node, batch []node) [][]node {
+	if cap(d.found) < len(batch) {
+		d.found = make([][]node, len(batch))
+	}
+	found := d.found[:len(batch)]
+	lookup := func(start, stop int) {
+		for i := start; i < stop; i++ {
+			candidates := t1Nodes[hashStr(batch[i].Hash())]
+			if d.verifyMatches {
+				candidates = verifiedCandidates(candidates, batch[i])
 			}
+			found[i] = candidates
+		}
+	}
+
+	if d.workers < 2 || len(batch) < minWorkerBatch {
+		lookup(0, len(batch))
+		return found
+	}
+
+	var wg sync.WaitGroup
+	size := (len(batch) + d.workers - 1) / d.workers
+	for start := 0; start < len(batch); start += size {
+		stop := start + size
+		if stop > len(batch) {
+			stop = len(batch)
+		}
+		wg.Add(1)
+		go func(start, stop int) {
+			lookup(start, stop)
+			wg.Done()
+		}(start, stop)
+	}
+	wg.Wait()
+	return found
+}
 
-			considering--
-			if considering == 0 {
-				done <- struct{}{}
-				break
+// verifiedCandidates filters out candidates that only share a hash with n2
+func verifiedCandidates(candidates []node, n2 node) []node {
+	verified := make([]node, 0, len(candidates))
+	for _, can := range candidates {
+		if nodesEqual(can, n2) {
+			verified = append(verified, can)
+		}
+	}
+	return verified
+}
+
+// unmatchedCandidates drops candidates that were matched by an earlier node,
+// keeping matches one-to-one. Lookups for a batch run before any of its
+// matches are made, so candidates are filtered as matches are applied
+func (d *diff) unmatchedCandidates(candidates []node) []node {
+	for i, can := range candidates {
+		if d.match(can) == nil {
+			continue
+		}
+		unmatched := append([]node(nil), candidates[:i]...)
+		for _, can := range candidates[i+1:] {
+			if d.match(can) == nil {
+				unmatched = append(unmatched, can)
 			}
 		}
-	}()
+		return unmatched
+	}
+	return candidates
+}
 
-	// start queue with t2 (root of tree)
-	queue <- t2
-	<-done
-	return
+// pairNodes matches n1 & n2 to each other, dropping any match either node
+// already has so every node has at most one counterpart
+func (d *diff) pairNodes(n1, n2 node) {
+	d.unmatch(n1)
+	d.unmatch(n2)
+	d.setMatch(n1, n2)
+	d.setMatch(n2, n1)
 }
 
 // matchNodes connects two nodes & tries to propagate that match upward to
 // ancestors so long as labels match
-func matchNodes(n1, n2 node) {
-	n1.SetMatch(n2)
-	n2.SetMatch(n1)
+func (d *diff) matchNodes(n1, n2 node) {
+	d.pairNodes(n1, n2)
 	n1p := n1.Parent()
 	n2p := n2.Parent()
-	for n1p != nil && n2p != nil {
-		if n1p.Addr().Eq(n2p.Addr()) {
-			n1p.SetMatch(n2p)
-			n2p.SetMatch(n1p)
-			n1p = n1p.Parent()
-			n2p = n2p.Parent()
+	// climb while addresses & types agree. stop at ancestors that are already
+	// matched, their own ancestors were considered when they were matched
+	for n1p != nil && n2p != nil && n1p.Addr().Eq(n2p.Addr()) && n1p.Type() == n2p.Type() {
+		if d.match(n1p) != nil || d.match(n2p) != nil {
+			break
 		}
-		break
+		d.setMatch(n1p, n2p)
+		d.setMatch(n2p, n1p)
+		n1p = n1p.Parent()
+		n2p = n2p.Parent()
 	}
 }
 
-func bestCandidate(t1Candidates []node, n2 node, t2Weight int) {
+func (d *diff) bestCandidate(t1Candidates []node, n2 node, t2Weight int) {
 	if n2.Parent() == nil {
 		return
 	}
 
-	// Copy the candidate list so that this slice can be modified
-	nodeList := make([]node, len(t1Candidates))
-	copy(nodeList, t1Candidates)
+	// Copy the candidate list so that this slice can be modified. Candidates
+	// are considered one node at a time, so the copy is reused between calls
+	d.climbing = append(d.climbing[:0], t1Candidates...)
+	nodeList := d.climbing
 
 	maxDist := 1 + float32(n2.Weight())/float32(t2Weight)
 	dist := 1 + float32(n2.Parent().Weight()-n2.Weight())/float32(t2Weight)
@@ -206,9 +488,10 @@ func bestCandidate(t1Candidates []node, n2 node, t2Weight int) {
 			if can == nil {
 				continue
 			}
-			if cp := can.Parent(); cp != nil {
-				if n2.Addr().Eq(cp.Addr()) {
-					matchNodes(cp, n2)
+			// parents that are already matched can't be matched again
+			if cp := can.Parent(); cp != nil && d.match(cp) == nil && d.match(n2) == nil {
+				if n2.Addr().Eq(cp.Addr()) && n2.Type() == cp.Type() {
+					d.matchNodes(cp, n2)
 					return
 				}
 			}
@@ -223,112 +506,224 @@ func bestCandidate(t1Candidates []node, n2 node, t2Weight int) {
 	}
 }
 
+// optimizeFixpoint runs optimize passes until a pass adds no new matches, or
+// the configured number of passes runs out. Each pass propagates matches one
+// step further, so deeply nested changes can take a number of passes to
+// settle. The net number of matches added by each pass is recorded in stats
+func (d *diff) optimizeFixpoint(t1, t2 node) {
+	// roots always share a position, give propagation a place to start from
+	if _, ok := t1.(compound); ok && t1.Type() == t2.Type() && d.match(t1) == nil && d.match(t2) == nil {
+		d.setMatch(t1, t2)
+		d.setMatch(t2, t1)
+	}
+
+	matched := d.matchCount()
+	for pass := 0; pass < d.maxOptimizePasses; pass++ {
+		d.optimize(t1, t2)
+		count := d.matchCount()
+		if d.stats != nil {
+			d.stats.OptimizePasses = append(d.stats.OptimizePasses, count-matched)
+		}
+		if count <= matched {
+			break
+		}
+		matched = count
+	}
+}
+
+// matchCount is the number of nodes in either tree with a match
+func (d *diff) matchCount() (count int) {
+	for _, m := range d.matches {
+		if m != nil {
+			count++
+		}
+	}
+	return count
+}
+
 func (d *diff) optimize(t1, t2 node) {
 	walkPostfix(t1, nil, func(_ []Addr, n node) {
-		propagateMatchToParent(n)
+		d.propagateMatchToParent(n)
 	})
 	walkPostfix(t2, nil, func(_ []Addr, n node) {
-		propagateMatchToParent(n)
+		d.propagateMatchToParent(n)
 	})
 
 	walk(t1, nil, func(_ []Addr, n node) bool {
-		propagateMatchToChildren(n)
+		d.propagateMatchToChildren(n)
 		return true
 	})
 	walk(t2, nil, func(_ []Addr, n node) bool {
-		propagateMatchToChildren(n)
+		d.propagateMatchToChildren(n)
 		return true
 	})
 }
 
-func propagateMatchToParent(n node) {
+func (d *diff) propagateMatchToParent(n node) {
 	// if n is a compound type that isn't matched
-	if cmp, ok := n.(compound); ok && n.Match() == nil {
+	if cmp, ok := n.(compound); ok && d.match(n) == nil {
 		var match node
-		// iterate each child
+		// iterate each child in address order
 		for _, ch := range cmp.Children() {
 			// if this child has a match, and the matches parent doesn't have a match,
-			// match the parents
-			if m := ch.Match(); m != nil && m.Parent() != nil && m.Parent().Match() == nil {
+			// match the parents. parents need the same type, arrays can't be diffed
+			// as objects
+			if m := d.match(ch); m != nil && m.Parent() != nil && d.match(m.Parent()) == nil && m.Parent().Type() == n.Type() {
 				p := m.Parent()
 				if match == nil {
 					match = p
-				} else if p.Weight() > m.Weight() {
-					// if a match already exists, keep the heavier match
+				} else if p.Weight() > match.Weight() {
+					// if a match already exists, keep the heavier match. ties go to the
+					// first match found, keeping matching deterministic
 					match = p
 				}
 			}
 		}
 		if match != nil {
-			matchNodes(match, n)
+			d.matchNodes(match, n)
 		}
 	}
 }
 
-func propagateMatchToChildren(n node) {
+func (d *diff) propagateMatchToChildren(n node) {
 	// if a node is matched & a compound type,
-	if n1, ok := n.(compound); ok && n.Match() != nil {
-		if n2, ok := n.Match().(compound); ok {
+	if n1, ok := n.(compound); ok && d.match(n) != nil {
+		if n2, ok := d.match(n).(compound); ok {
 			if n1.Type() == ntObject && n2.Type() == ntObject {
 				// match any key names
 				for _, n1ch := range n1.Children() {
-					if n2ch := n2.Child(n1ch.Addr()); n2ch != nil {
-						n2ch.SetMatch(n1ch)
-						n1ch.SetMatch(n2ch)
+					if n2ch := n2.Child(n1ch.Addr()); n2ch != nil && mappable(n1ch, n2ch) {
+						d.pairNodes(n1ch, n2ch)
 					}
 				}
 			}
-			if n1.Type() == ntArray && n2.Type() == ntArray && len(n1.Children()) == len(n2.Children()) {
-				// if arrays are the same length, match all children
+			if n1.Type() == ntArray && n2.Type() == ntArray && (unordered(n1) || unordered(n2)) {
+				// arrays that ignore order match children by hash
+				d.matchUnorderedChildren(n1.(*array), n2.(*array))
+			} else if n1.Type() == ntArray && n2.Type() == ntArray {
+				// align arrays as sequences. if alignment gives up & arrays are the same
+				// length, match all children by position
 				// b/c these are arrays, no names should be missing, safe to skip a name check
-				for _, n1ch := range n1.Children() {
-					n2ch := n2.Child(n1ch.Addr())
-					n2ch.SetMatch(n1ch)
-					n1ch.SetMatch(n2ch)
+				if !d.alignChildren(n1.(*array), n2.(*array)) && len(n1.Children()) == len(n2.Children()) {
+					for _, n1ch := range n1.Children() {
+						if n2ch := n2.Child(n1ch.Addr()); mappable(n1ch, n2ch) {
+							d.pairNodes(n1ch, n2ch)
+						}
+					}
 				}
 			}
 		}
 	}
 }
 
-// calculate inserts, deletes, and maybe changes by folding tree A into
-// tree B, adding unmatched nodes from A to B as deletes
-func (d *diff) calcDeltas(t1, t2 node) (dts Deltas) {
-	// fold t1 into t2, adding deletes to t2
-	walkSorted(t1, nil, func(p []Addr, n node) bool {
-		if n.Match() == nil {
-			n.SetChangeType(DTDelete)
+// dropMovedMatches removes matches between nodes that don't share a position.
+// Deltas can't describe a value moving to a different parent or object key,
+// or to a new place among the elements of an array, so those nodes are
+// treated as deleted & inserted instead
+func (d *diff) dropMovedMatches(t1, t2 node) {
+	walk(t2, nil, func(_ []Addr, n node) bool {
+		if d.moved(n) {
+			d.unmatch(n)
+		}
+		if arr, ok := n.(*array); ok {
+			d.dropReorderedMatches(arr)
+		}
+		return true
+	})
+	walk(t1, nil, func(_ []Addr, n node) bool {
+		// clear matches to nodes that no longer match anything
+		if m := d.match(n); m != nil && (d.match(m) == nil || d.moved(n)) {
+			d.unmatch(n)
+		}
+		return true
+	})
+}
 
-			if cmp, ok := n.(compound); ok {
-				cmp.DropChildNodes()
+// dropReorderedMatches removes matches of ordered array elements that are out
+// of order. The longest run of matched elements that keep their order stays
+// matched
+func (d *diff) dropReorderedMatches(b *array) {
+	a, ok := d.match(b).(*array)
+	if !ok || unordered(a) || unordered(b) {
+		return
+	}
+
+	// index of the element of a matched to ch. elements matched into other
+	// parents are dropped as moves
+	index := func(ch node) int {
+		if m := d.match(ch); m != nil && m.Parent() == node(a) {
+			if i, ok := m.Addr().Value().(int); ok {
+				return i
 			}
+		}
+		return -1
+	}
 
-			addNode(t2, n, p)
+	// most arrays keep their order, only collect matches of those that don't
+	last, ordered := -1, true
+	for _, ch := range b.children {
+		if i := index(ch); i >= 0 {
+			ordered = ordered && i > last
+			last = i
+		}
+	}
+	if ordered {
+		return
+	}
 
-			// update t1 array values to reflect deletion so later comparisons will be
-			// accurate. only place where this really applies is parent of delete is
-			// an array (object paths will remain accurate)
-			if parent := n.Parent(); parent != nil {
-				if arr, ok := parent.(*array); ok {
-					idx, ok := n.Addr().Value().(int)
-					if  !ok {
-						panic("expected int type for array address")
-					}
-					for i, n := range arr.Children() {
-						addr := IndexAddr(i - 1)
-						arr.childNames[addr] = i - 1
-						if i > idx {
-							n.SetAddr(addr)
-						}
-					}
+	var (
+		pairs   [][2]int
+		matched []node
+	)
+	for j, ch := range b.children {
+		if i := index(ch); i >= 0 {
+			pairs = append(pairs, [2]int{i, j})
+			matched = append(matched, ch)
+		}
+	}
+	for x, mv := range moves(pairs) {
+		if mv {
+			d.unmatch(matched[x])
+		}
+	}
+}
+
+// moved returns true if a node is matched to a node in a different position
+func (d *diff) moved(n node) bool {
+	m := d.match(n)
+	if m == nil {
+		return false
+	}
+	np, mp := n.Parent(), m.Parent()
+	if np == nil || mp == nil {
+		return np != mp
+	}
+	if d.match(np) != mp {
+		return true
+	}
+	return np.Type() == ntObject && !n.Addr().Eq(m.Addr())
+}
+
+// calculate inserts, deletes, and maybe changes by folding tree A into
+// tree B, adding unmatched nodes from A to B as deletes. t1 may be shared
+// between diffs, so it's never modified, only t2 & diff state
+func (d *diff) calcDeltas(t1, t2 node) (dts Deltas) {
+	// fold t1 into t2, adding deletes to t2
+	d.walkSorted(t1, nil, func(p []Addr, n node) bool {
+		if d.match(n) == nil {
+			d.setChangeType(n, DTDelete)
+			// walking stops at unmatched nodes, so the parent of a delete is always
+			// matched. add the delete to its parent's match in t2
+			if n.Parent() != nil {
+				if parent, ok := d.match(n.Parent()).(compound); ok {
+					parent.AddChild(n)
 				}
 			}
 
-			// at this point we have the most general insert we know of
-			if cmp, ok := n.(compound); ok {
-				// drop any childs node references so subsequent iterations of the
-				// tree to build up a delta don't iterate any deeper
-				cmp.DropChildNodes()
+			// deleted array elements are addressed by where they sit among the
+			// elements of the new array (object paths will remain accurate)
+			if arr, ok := n.Parent().(*array); ok {
+				d.setAddr(n, d.deleteIndex(n, arr))
 			}
 
 			// by returning false here we stop traversing to any existing children
@@ -338,35 +733,16 @@ func (d *diff) calcDeltas(t1, t2 node) (dts Deltas) {
 		return true
 	})
 
-	walkSorted(t2, nil, func(p []Addr, n node) bool {
+	d.walkSorted(t2, nil, func(p []Addr, n node) bool {
 		// at this point deletes from t1 have been moved here, need to skip 'em
 		// because n.Match will be a circular reference
-		if n.ChangeType() == DTDelete {
+		if d.changeType(n) == DTDelete {
 			return false
 		}
 
-		match := n.Match()
+		match := d.match(n)
 		if match == nil {
-			n.SetChangeType(DTInsert)
-
-			// update t1 array values to reflect insertion so later comparisons will be
-			// accurate. only place where this really applies is parent of insert is
-			// an array (object paths will remain accurate)
-			if parent := n.Parent(); parent != nil && parent.Type() == ntArray {
-				if match, ok := parent.Match().(*array); ok && match != nil {
-					idx, ok := n.Addr().Value().(int)
-					if !ok {
-						panic("array address index is not of integer type")
-					}
-					for i, n := range match.Children() {
-						a := IndexAddr(i + 1)
-						match.childNames[a] = i + 1
-						if i > idx {
-							n.SetAddr(a)
-						}
-					}
-				}
-			}
+			d.setChangeType(n, DTInsert)
 
 			// at this point we have the most general insert we know of
 			if cmp, ok := n.(compound); ok {
@@ -385,7 +761,7 @@ func (d *diff) calcDeltas(t1, t2 node) (dts Deltas) {
 			// TODO (b5): this needs to be a check to see if it's a leaf node
 			// (eg, empty object is a leaf node)
 			if delta := compareScalar(match, n, p[len(p)-1]); delta != nil {
-				n.SetChangeType(DTUpdate)
+				d.setChangeType(n, DTUpdate)
 				// TODO (b5) - restore support for change calculation, add tests
 				// if d.changes {
 				// 	// addDelta(root, delta, p)
@@ -406,9 +782,9 @@ func (d *diff) calcDeltas(t1, t2 node) (dts Deltas) {
 	// special case where root elements aren't matched. If this happends t1 root
 	// will never be considered
 	var script Deltas
-	if t2.Match() == nil {
-		del := toDelta(t1)
-		ins := toDelta(t2)
+	if d.match(t2) == nil {
+		del := d.toDelta(t1)
+		ins := d.toDelta(t2)
 		script = Deltas{del, ins}
 	} else {
 		script, _ = d.childDeltas(t2.(compound))
@@ -419,11 +795,70 @@ func (d *diff) calcDeltas(t1, t2 node) (dts Deltas) {
 	return script
 }
 
+// deleteIndex calculates the address of a deleted array element. deltas are
+// applied in order of address, deletes first, so a deleted element is
+// addressed just after the new position of the nearest preceding element
+// that was kept. Kept elements of unordered arrays can swap places, so
+// deletes are addressed by where the element sits once earlier deletes &
+// inserts addressed before it are applied. Addresses are calculated for every
+// element of an array at once, & kept for later deletes from the same array
+func (d *diff) deleteIndex(n node, arr *array) Addr {
+	idx, ok := n.Addr().Value().(int)
+	if !ok {
+		panic("expected int type for array address")
+	}
+	if addrs, ok := d.deleteAddrs[arr]; ok {
+		return IndexAddr(addrs[idx])
+	}
+
+	addrs := make([]int, len(arr.children))
+	if m, ok := d.match(arr).(*array); ok && (unordered(arr) || unordered(m)) {
+		// new array indexes of inserted elements, in order. deleted elements
+		// folded into the new array are skipped, their parent is arr
+		var inserts []int
+		for j, ch := range m.children {
+			if ch.Parent() == node(m) && d.match(ch) == nil {
+				inserts = append(inserts, j)
+			}
+		}
+		deleted, inserted := 0, 0
+		for i, ch := range arr.children {
+			// inserts apply before a delete with a greater address, shifting the
+			// element right. deletes apply first at equal addresses
+			pos := i - deleted + inserted
+			for inserted < len(inserts) && inserts[inserted] < pos {
+				inserted++
+				pos++
+			}
+			addrs[i] = pos
+			if d.match(ch) == nil {
+				deleted++
+			}
+		}
+	} else {
+		next := 0
+		for i, ch := range arr.children {
+			addrs[i] = next
+			if m := d.match(ch); m != nil {
+				if j, ok := m.Addr().Value().(int); ok && m.Parent() == d.match(arr) {
+					next = j + 1
+				}
+			}
+		}
+	}
+
+	if d.deleteAddrs == nil {
+		d.deleteAddrs = map[*array][]int{}
+	}
+	d.deleteAddrs[arr] = addrs
+	return IndexAddr(addrs[idx])
+}
+
 func (d *diff) childDeltas(cmp compound) (changes Deltas, hasChanges bool) {
 	ch := cmp.Children()
 	for _, n := range ch {
-		dlt := toDelta(n)
-		if dlt.Type == DTContext {
+		dlt := d.toDelta(n)
+		if dlt.Type == DTContext || dlt.Type == DTRename {
 			if childCmp, ok := n.(compound); ok {
 				if children, childChanges := d.childDeltas(childCmp); childChanges {
 					hasChanges = true
@@ -431,11 +866,17 @@ func (d *diff) childDeltas(cmp compound) (changes Deltas, hasChanges bool) {
 					dlt.Deltas = children
 				}
 			}
-		} else {
+		}
+		if dlt.Type != DTContext {
 			hasChanges = true
 		}
 
+		if dlt.Type == DTUpdate && d.textThreshold > 0 {
+			dlt.TextDiff = d.textDiff(dlt.SourceValue, dlt.Value)
+		}
+
 		// If we aren't outputting changes, convert to a delete/insert combo
+		// any text diff stays with the insert
 		if dlt.Type == DTUpdate && !d.changes {
 			changes = append(changes, &Delta{Type: DTDelete, Path: dlt.Path, Value: dlt.SourceValue})
 			dlt.Type = DTInsert
@@ -449,7 +890,8 @@ func (d *diff) childDeltas(cmp compound) (changes Deltas, hasChanges bool) {
 }
 
 func sortDeltasAndMaybeCalcStats(deltas Deltas, st *Stats) {
-	sort.Sort(deltas)
+	// stable sort keeps output deterministic for deltas that compare equal
+	sort.Stable(deltas)
 
 	for _, d := range deltas {
 		if len(d.Deltas) > 0 {
@@ -464,6 +906,8 @@ func sortDeltasAndMaybeCalcStats(deltas Deltas, st *Stats) {
 				st.Updates++
 			case DTDelete:
 				st.Deletes++
+			case DTRename:
+				st.Renames++
 			}
 		}
 	}
@@ -518,13 +962,13 @@ func sortDeltasAndMaybeCalcStats(deltas Deltas, st *Stats) {
 // func movedBNodes(allA, allB []node) []*Delta {
 // 	var a, b []node
 // 	for _, n := range allA {
-// 		if n.Match() != nil {
+// 		if d.match(n) != nil {
 // 			a = append(a, n)
 // 		}
 // 	}
 
 // 	for _, n := range allB {
-// 		if n.Match() != nil {
+// 		if d.match(n) != nil {
 // 			b = append(b, n)
 // 		}
 // 	}
@@ -672,6 +1116,21 @@ func sortDeltasAndMaybeCalcStats(deltas Deltas, st *Stats) {
 
 // compareScalar compares two scalar values, possibly creating an Update delta
 func compareScalar(n1, n2 node, n2Addr Addr) *Delta {
+	// values reduced to keys by a comparator are equal if their keys are
+	s1, ok1 := n1.(*scalar)
+	s2, ok2 := n2.(*scalar)
+	if ok1 && ok2 && s1.keyed && s2.keyed {
+		if s1.key == s2.key {
+			return nil
+		}
+		return &Delta{
+			Type:        DTUpdate,
+			Path:        n2Addr,
+			Value:       n2.Value(),
+			SourceValue: n1.Value(),
+		}
+	}
+
 	if n1.Type() != n2.Type() {
 		return &Delta{
 			Type:        DTUpdate,
@@ -691,19 +1150,22 @@ func compareScalar(n1, n2 node, n2Addr Addr) *Delta {
 	return nil
 }
 
-func toDelta(n node) *Delta {
-	d := &Delta{Type: n.ChangeType(), Path: n.Addr()}
-	if string(d.Type) == "" {
-		d.Type = DTContext
+func (d *diff) toDelta(n node) *Delta {
+	dlt := &Delta{Type: d.changeType(n), Path: d.addr(n)}
+	if string(dlt.Type) == "" {
+		dlt.Type = DTContext
 	}
 
-	switch d.Type {
+	switch dlt.Type {
 	case DTUpdate:
-		d.Value = n.Value()
-		d.SourceValue = n.Match().Value()
+		dlt.Value = n.Value()
+		dlt.SourceValue = d.match(n).Value()
+	case DTRename:
+		dlt.Value = n.Value()
+		dlt.SourcePath = d.match(n).Addr().String()
 	case DTInsert, DTDelete, DTContext:
-		d.Value = n.Value()
+		dlt.Value = n.Value()
 	}
 
-	return d
+	return dlt
 }
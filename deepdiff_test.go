@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"strings"
 	"testing"
@@ -147,6 +148,36 @@ func RunTestCases(t *testing.T, cases []TestCase, opts ...DiffOption) {
 	}
 }
 
+// RunDeltaTestCases checks diff scripts without patching, for configurations
+// where patching source won't produce an identical destination
+func RunDeltaTestCases(t *testing.T, cases []TestCase, opts ...DiffOption) {
+	var (
+		dd  = New(opts...)
+		ctx = context.Background()
+	)
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			var src, dst interface{}
+			if err := json.Unmarshal([]byte(c.src), &src); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(c.dst), &dst); err != nil {
+				t.Fatal(err)
+			}
+
+			diff, err := dd.Diff(ctx, src, dst)
+			if err != nil {
+				t.Fatalf("Diff error: %s", err)
+			}
+
+			if diffDiff := cmp.Diff(c.expect, diff); diffDiff != "" {
+				t.Errorf("diff script response mismatch (-want +got):\n%s", diffDiff)
+			}
+		})
+	}
+}
+
 func TestBasicDiffing(t *testing.T) {
 	cases := []TestCase{
 		{
@@ -294,6 +325,93 @@ func TestChangeDiffs(t *testing.T) {
 	RunTestCases(t, cases, func(c *Config) { c.CalcChanges = true })
 }
 
+func TestMissingEquivalence(t *testing.T) {
+	RunDeltaTestCases(t, []TestCase{
+		{
+			"null as missing",
+			`{"a":1,"b":null,"c":[null]}`,
+			`{"a":1,"c":[null]}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("a"), Value: float64(1)},
+				{Type: DTContext, Path: StringAddr("c"), Value: []interface{}{nil}},
+			},
+		},
+		{
+			"null to value is an insert",
+			`{"a":1,"b":null}`,
+			`{"a":1,"b":2}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("a"), Value: float64(1)},
+				{Type: DTInsert, Path: StringAddr("b"), Value: float64(2)},
+			},
+		},
+		{
+			"empty array is not missing",
+			`{"a":1,"b":null}`,
+			`{"a":1,"b":[]}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("a"), Value: float64(1)},
+				{Type: DTInsert, Path: StringAddr("b"), Value: []interface{}{}},
+			},
+		},
+	}, func(c *Config) { c.NullAsMissing = true })
+
+	RunDeltaTestCases(t, []TestCase{
+		{
+			"empty array as missing",
+			`{"a":1,"b":[]}`,
+			`{"a":1}`,
+			Deltas{{Type: DTContext, Path: StringAddr("a"), Value: float64(1)}},
+		},
+		{
+			"null is not missing",
+			`{"a":1,"b":[]}`,
+			`{"a":1,"b":null}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("a"), Value: float64(1)},
+				{Type: DTInsert, Path: StringAddr("b"), Value: nil},
+			},
+		},
+	}, func(c *Config) { c.EmptyArrayAsMissing = true })
+
+	RunDeltaTestCases(t, []TestCase{
+		{
+			"nested empty values",
+			`{"a":1,"b":null,"c":{"d":[],"e":{}}}`,
+			`{"a":1,"c":{}}`,
+			Deltas{{Type: DTContext, Path: StringAddr("a"), Value: float64(1)}},
+		},
+		{
+			"equivalent values",
+			`{"a":1,"b":null,"c":[],"d":{}}`,
+			`{"a":1,"b":{},"c":null,"d":[]}`,
+			Deltas{{Type: DTContext, Path: StringAddr("a"), Value: float64(1)}},
+		},
+		{
+			"array elements compare as null",
+			`{"a":[1,null]}`,
+			`{"a":[1,{"b":[]}]}`,
+			Deltas{{Type: DTContext, Path: StringAddr("a"), Value: []interface{}{float64(1), map[string]interface{}{"b": []interface{}{}}}}},
+		},
+		{
+			"changes alongside missing values",
+			`{"a":1,"b":null,"c":{"d":true,"e":[]}}`,
+			`{"a":1,"c":{"d":false}}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("a"), Value: float64(1)},
+				{Type: DTContext, Path: StringAddr("c"), Deltas: Deltas{
+					{Type: DTDelete, Path: StringAddr("d"), Value: true},
+					{Type: DTInsert, Path: StringAddr("d"), Value: false},
+				}},
+			},
+		},
+	}, func(c *Config) {
+		c.NullAsMissing = true
+		c.EmptyArrayAsMissing = true
+		c.EmptyObjectAsMissing = true
+	})
+}
+
 func TestDeltaSorting(t *testing.T) {
 	cases := []TestCase{
 		{
@@ -382,6 +500,123 @@ func TestInsertGeneralizing(t *testing.T) {
 	RunTestCases(t, cases)
 }
 
+func TestArrayDeleteAddressing(t *testing.T) {
+	cases := []TestCase{
+		{
+			"delete after moved element",
+			`["a","b","c","d"]`,
+			`["b","a","d"]`,
+			Deltas{
+				{Type: DTInsert, Path: IndexAddr(0), Value: "b"},
+				{Type: DTContext, Path: IndexAddr(1), Value: "a"},
+				{Type: DTDelete, Path: IndexAddr(2), Value: "b"},
+				{Type: DTDelete, Path: IndexAddr(2), Value: "c"},
+				{Type: DTContext, Path: IndexAddr(2), Value: "d"},
+			},
+		},
+		{
+			"deletes between inserts",
+			`["x","a","b","y","c"]`,
+			`["a","z","b","c","w"]`,
+			Deltas{
+				{Type: DTDelete, Path: IndexAddr(0), Value: "x"},
+				{Type: DTContext, Path: IndexAddr(0), Value: "a"},
+				{Type: DTInsert, Path: IndexAddr(1), Value: "z"},
+				{Type: DTContext, Path: IndexAddr(2), Value: "b"},
+				{Type: DTDelete, Path: IndexAddr(3), Value: "y"},
+				{Type: DTContext, Path: IndexAddr(3), Value: "c"},
+				{Type: DTInsert, Path: IndexAddr(4), Value: "w"},
+			},
+		},
+		{
+			"trailing deletes after inserts",
+			`["2","2","1",["4"]]`,
+			`["1","2",["1"],["4"],"2"]`,
+			Deltas{
+				{Type: DTInsert, Path: IndexAddr(0), Value: "1"},
+				{Type: DTContext, Path: IndexAddr(1), Value: "2"},
+				{Type: DTInsert, Path: IndexAddr(2), Value: []interface{}{"1"}},
+				{Type: DTInsert, Path: IndexAddr(3), Value: []interface{}{"4"}},
+				{Type: DTContext, Path: IndexAddr(4), Value: "2"},
+				{Type: DTDelete, Path: IndexAddr(5), Value: "1"},
+				{Type: DTDelete, Path: IndexAddr(5), Value: []interface{}{"4"}},
+			},
+		},
+	}
+
+	RunTestCases(t, cases)
+}
+
+func TestOneToOneMatches(t *testing.T) {
+	cases := []TestCase{
+		{
+			"equal subtrees share a candidate",
+			`[true,[["d"]],{}]`,
+			`[{},[[],["d"],1],{}]`,
+			Deltas{
+				{Type: DTDelete, Path: IndexAddr(0), Value: true},
+				{Type: DTInsert, Path: IndexAddr(0), Value: map[string]interface{}{}},
+				{Type: DTContext, Path: IndexAddr(1), Deltas: Deltas{
+					{Type: DTInsert, Path: IndexAddr(0), Value: []interface{}{}},
+					{Type: DTContext, Path: IndexAddr(1), Value: []interface{}{"d"}},
+					{Type: DTInsert, Path: IndexAddr(2), Value: float64(1)},
+				}},
+				{Type: DTContext, Path: IndexAddr(2), Value: map[string]interface{}{}},
+			},
+		},
+		{
+			"object replaced by an array",
+			`{"c":true,"f":{"a":{"a":2,"e":"q"},"c":"s"}}`,
+			`{"c":true,"f":{"a":["q","r"],"c":"s"}}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("c"), Value: true},
+				{Type: DTContext, Path: StringAddr("f"), Deltas: Deltas{
+					{Type: DTDelete, Path: StringAddr("a"), Value: map[string]interface{}{"a": float64(2), "e": "q"}},
+					{Type: DTInsert, Path: StringAddr("a"), Value: []interface{}{"q", "r"}},
+					{Type: DTContext, Path: StringAddr("c"), Value: "s"},
+				}},
+			},
+		},
+	}
+
+	RunTestCases(t, cases)
+}
+
+func TestPatchRandomDiffs(t *testing.T) {
+	var (
+		ctx = context.Background()
+		rnd = rand.New(rand.NewSource(11))
+		dd  = New()
+	)
+
+	for i := 0; i < 3000; i++ {
+		a := randomDoc(rnd, 6)
+		b := mutateDoc(rnd, a, 6)
+		// diffs of scalar roots aren't supported
+		if scalarValue(a) || scalarValue(b) {
+			continue
+		}
+
+		d := dd.newDiff(a, b)
+		deltas := d.diff(ctx)
+		oneToOne := func(_ []Addr, n node) bool {
+			if m := d.match(n); m != nil && d.match(m) != n {
+				t.Errorf("case %d: %s is matched to %s, which is matched elsewhere", i, pathString(path(n)), pathString(path(m)))
+			}
+			return true
+		}
+		walk(d.t1, nil, oneToOne)
+		walk(d.t2, nil, oneToOne)
+
+		if err := checkPatch(deltas, a, b); err != nil {
+			ad, _ := json.Marshal(a)
+			bd, _ := json.Marshal(b)
+			dd, _ := json.Marshal(deltas)
+			t.Fatalf("case %d: %s\na: %s\nb: %s\ndeltas: %s", i, err, ad, bd, dd)
+		}
+	}
+}
+
 func TestNestedScalar(t *testing.T) {
 	cases := []TestCase{
 		{
@@ -389,19 +624,11 @@ func TestNestedScalar(t *testing.T) {
 			`{ "structure": { "formatConfig": { "headerRow": false }}}`,
 			`{ "structure": { "formatConfig": { "headerRow": true }}}`,
 			Deltas{
-				{Type: DTDelete, Path: RootAddr{}, Value: map[string]interface{}{
-					"structure" : map[string]interface{}{
-						"formatConfig": map[string]interface{}{
-							"headerRow": false,
-						},
-					},
-				}},
-				{Type: DTInsert, Path: RootAddr{}, Value: map[string]interface{}{
-					"structure" : map[string]interface{}{
-						"formatConfig": map[string]interface{}{
-							"headerRow": true,
-						},
-					},
+				{Type: DTContext, Path: StringAddr("structure"), Deltas: Deltas{
+					{Type: DTContext, Path: StringAddr("formatConfig"), Deltas: Deltas{
+						{Type: DTDelete, Path: StringAddr("headerRow"), Value: false},
+						{Type: DTInsert, Path: StringAddr("headerRow"), Value: true},
+					}},
 				}},
 			},
 		},
@@ -428,6 +655,81 @@ func TestNestedScalar(t *testing.T) {
 	makeDotGraph(aJSON, bJSON, "testdata/graphs/nested_scalar")
 }
 
+func TestDeeplyNestedChanges(t *testing.T) {
+	cases := []TestCase{
+		{
+			"values repeated at other depths",
+			`{"x":{"y":{"z":{"k":"v","n":1}}},"w":"v"}`,
+			`{"x":{"y":{"z":{"k":"v","n":2},"m":1}},"q":"v"}`,
+			Deltas{
+				{Type: DTInsert, Path: StringAddr("q"), Value: "v"},
+				{Type: DTDelete, Path: StringAddr("w"), Value: "v"},
+				{Type: DTContext, Path: StringAddr("x"), Deltas: Deltas{
+					{Type: DTContext, Path: StringAddr("y"), Deltas: Deltas{
+						{Type: DTInsert, Path: StringAddr("m"), Value: float64(1)},
+						{Type: DTContext, Path: StringAddr("z"), Deltas: Deltas{
+							{Type: DTContext, Path: StringAddr("k"), Value: "v"},
+							{Type: DTDelete, Path: StringAddr("n"), Value: float64(1)},
+							{Type: DTInsert, Path: StringAddr("n"), Value: float64(2)},
+						}},
+					}},
+				}},
+			},
+		},
+		{
+			"changes at every level",
+			`{"a":{"b":{"c":{"d":"u","e":1},"f":"u"},"g":"u"}}`,
+			`{"a":{"b":{"c":{"d":"u","e":2},"f":"w"},"g":"w"}}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("a"), Deltas: Deltas{
+					{Type: DTContext, Path: StringAddr("b"), Deltas: Deltas{
+						{Type: DTContext, Path: StringAddr("c"), Deltas: Deltas{
+							{Type: DTContext, Path: StringAddr("d"), Value: "u"},
+							{Type: DTDelete, Path: StringAddr("e"), Value: float64(1)},
+							{Type: DTInsert, Path: StringAddr("e"), Value: float64(2)},
+						}},
+						{Type: DTDelete, Path: StringAddr("f"), Value: "u"},
+						{Type: DTInsert, Path: StringAddr("f"), Value: "w"},
+					}},
+					{Type: DTDelete, Path: StringAddr("g"), Value: "u"},
+					{Type: DTInsert, Path: StringAddr("g"), Value: "w"},
+				}},
+			},
+		},
+	}
+
+	RunTestCases(t, cases)
+}
+
+func TestOptimizePasses(t *testing.T) {
+	var a, b interface{}
+	if err := json.Unmarshal([]byte(`{"a":{"b":{"c":{"d":[1,2,3],"e":"f"}}},"g":[{"h":1},{"h":2}]}`), &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`{"a":{"b":{"c":{"d":[1,2,4],"e":"f"}}},"g":[{"h":1},{"h":3}]}`), &b); err != nil {
+		t.Fatal(err)
+	}
+
+	_, st, err := New().StatDiff(context.Background(), a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.OptimizePasses) < 2 {
+		t.Fatalf("expected at least 2 passes, got: %v", st.OptimizePasses)
+	}
+	if last := st.OptimizePasses[len(st.OptimizePasses)-1]; last != 0 {
+		t.Errorf("expected passes to stop once no matches are added, last pass added %d", last)
+	}
+
+	_, st, err = New(func(cfg *Config) { cfg.MaxOptimizePasses = 1 }).StatDiff(context.Background(), a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.OptimizePasses) != 1 {
+		t.Errorf("expected passes to be capped at 1, got: %v", st.OptimizePasses)
+	}
+}
+
 func TestRootChanges(t *testing.T) {
 	t.Skip("TODO (b5) - fix this with 'null' as the position indicator for root object changes")
 
@@ -499,7 +801,7 @@ func makeDotGraph(aJSON, bJSON, filename string) {
 		panic(err)
 	}
 
-	d := &diff{d1: a, d2: b}
+	d := New().newDiff(a, b)
 	d.t1, d.t2, d.t1Nodes = d.prepTrees(context.Background())
 	d.queueMatch(d.t1Nodes, d.t2)
 	d.optimize(d.t1, d.t2)
@@ -563,8 +865,8 @@ func dotGraphTree(d *diff) *bytes.Buffer {
 
 	walk(d.t2, nil, func(p []Addr, n node) bool {
 		nID := mkID("t2", n)
-		if n.Match() != nil {
-			fmt.Fprintf(buf, "  %s -> %s[color=red,penwidth=1.0];\n", nID, mkID("t1", n.Match()))
+		if m := d.match(n); m != nil {
+			fmt.Fprintf(buf, "  %s -> %s[color=red,penwidth=1.0];\n", nID, mkID("t1", m))
 		}
 		return true
 	})
@@ -648,6 +950,8 @@ func TestDiffStats(t *testing.T) {
 		LeftWeight:  107,
 		RightWeight: 13,
 		Deletes:     2,
+
+		OptimizePasses: []int{2, 0},
 	}
 	if diff := cmp.Diff(expectStat, stat); diff != "" {
 		t.Errorf("result mismatch. (-want +got):\n%s", diff)
@@ -732,3 +1036,124 @@ func BenchmarkDiff5MB(b *testing.B) {
 		diff.Diff(ctx, t1, t2)
 	}
 }
+
+// makeRows generates a wide document of n object rows. every changeEvery-th
+// row has a modified value
+func makeRows(n, changeEvery int) []interface{} {
+	rows := make([]interface{}, n)
+	for i := range rows {
+		name := fmt.Sprintf("row %d", i)
+		if changeEvery > 0 && i%changeEvery == 0 {
+			name = fmt.Sprintf("changed row %d", i)
+		}
+		rows[i] = map[string]interface{}{
+			"id":    float64(i),
+			"name":  name,
+			"tags":  []interface{}{"a", "b", fmt.Sprintf("t%d", i%7)},
+			"score": float64(i%100) / 3,
+		}
+	}
+	return rows
+}
+
+func BenchmarkDiffWide(b *testing.B) {
+	var (
+		diff = New()
+		ctx  = context.Background()
+		t1   = makeRows(20000, 0)
+		t2   = makeRows(20000, 50)
+	)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		diff.Diff(ctx, t1, t2)
+	}
+}
+
+func TestDiffWorkers(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		t1     = makeRows(2000, 0)
+		t2     = makeRows(2000, 7)
+		expect string
+	)
+
+	for _, workers := range []int{1, 2, 8} {
+		dd := New(func(c *Config) { c.Workers = workers })
+		deltas, err := dd.Diff(ctx, t1, t2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(deltas)
+		if expect == "" {
+			expect = string(data)
+		} else if string(data) != expect {
+			t.Errorf("%d workers: result mismatch", workers)
+		}
+	}
+}
+
+func TestDeterministicDeltas(t *testing.T) {
+	// objects with many keys & repeated values give map iteration order plenty
+	// of chances to leak into matching
+	dupes := func(n int, changed string) map[string]interface{} {
+		obj := map[string]interface{}{}
+		for i := 0; i < n; i++ {
+			obj[fmt.Sprintf("k%d", i)] = map[string]interface{}{
+				"a": []interface{}{"x", "y", float64(i % 3)},
+				"b": map[string]interface{}{"c": "same"},
+			}
+		}
+		obj[changed] = "changed"
+		return obj
+	}
+
+	cases := []struct {
+		description string
+		a, b        interface{}
+	}{
+		{"repeated subtrees", dupes(40, "k3"), dupes(40, "k17")},
+		{"moved subtrees", map[string]interface{}{
+			"a": map[string]interface{}{"x": dupes(5, "k0"), "y": dupes(5, "k1")},
+			"b": dupes(5, "k2"),
+		}, map[string]interface{}{
+			"a": map[string]interface{}{"y": dupes(5, "k0"), "z": dupes(5, "k2")},
+			"c": dupes(5, "k1"),
+		}},
+		{"rows", makeRows(300, 0), makeRows(300, 11)},
+	}
+
+	ctx := context.Background()
+	for _, c := range cases {
+		for _, changes := range []bool{false, true} {
+			dd := New(func(cfg *Config) { cfg.CalcChanges = changes })
+			var expect string
+			for i := 0; i < 50; i++ {
+				deltas, err := dd.Diff(ctx, c.a, c.b)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := json.Marshal(deltas)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if i == 0 {
+					expect = string(data)
+				} else if string(data) != expect {
+					t.Fatalf("%s (changes: %t): run %d output differs from first run", c.description, changes, i)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkPrepareWide(b *testing.B) {
+	var (
+		dd   = New()
+		ctx  = context.Background()
+		data = makeRows(100000, 0)
+	)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dd.Prepare(ctx, data)
+	}
+}
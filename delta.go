@@ -20,6 +20,9 @@ const (
 	DTInsert = Operation("+")
 	// DTUpdate is an alteration of a scalar data type (string, bool, float, etc)
 	DTUpdate = Operation("~")
+	// DTRename moves the value of an object key to a new key. The original key
+	// is the delta's SourcePath, changes within the value are child deltas
+	DTRename = Operation("^")
 )
 
 // Addr is a single location within a data structure. Multiple path elements can
@@ -146,16 +149,23 @@ type Delta struct {
 	Value interface{} `json:"value"`
 
 	// To make delta's revesible, original values are included
-	// the original path this change from
+	// the original path this change from. Renames set SourcePath to the key
+	// the value is moved from
 	SourcePath string `json:"SourcePath,omitempty"`
 	// the original  value this was changed from, will not always be present
 	SourceValue interface{} `json:"originalValue,omitempty"`
+	// TextDiff describes changes within a string for updates to long strings
+	TextDiff TextDiff `json:"textDiff,omitempty"`
 
 	// Child Changes
 	Deltas `json:"deltas,omitempty"`
 }
 
-// MarshalJSON implements a custom JOSN Marshaller
+// MarshalJSON implements a custom JOSN Marshaller. Deltas are written as
+// tuples of [type, path, value], or [type, path, null, deltas] when the delta
+// has children. Optional fields follow as a trailing object, so renames end
+// with {"sourcePath": key} and updates with text diffs end with
+// {"textDiff": [[type, text], ...]}
 func (d *Delta) MarshalJSON() ([]byte, error) {
 	v := []interface{}{d.Type, d.Path}
 	if len(d.Deltas) > 0 {
@@ -163,9 +173,33 @@ func (d *Delta) MarshalJSON() ([]byte, error) {
 	} else {
 		v = append(v, d.Value)
 	}
+	if tr := d.trailer(); tr != nil {
+		v = append(v, tr)
+	}
 	return json.Marshal(v)
 }
 
+// trailer returns the optional fields that end the tuple form of a delta, or
+// nil if none are set. A trailer is always an object, which keeps it distinct
+// from the child deltas before it
+func (d *Delta) trailer() map[string]interface{} {
+	if d.SourcePath == "" && len(d.TextDiff) == 0 {
+		return nil
+	}
+	tr := map[string]interface{}{}
+	if d.SourcePath != "" {
+		tr["sourcePath"] = d.SourcePath
+	}
+	if len(d.TextDiff) > 0 {
+		edits := make([]interface{}, len(d.TextDiff))
+		for i, e := range d.TextDiff {
+			edits[i] = []interface{}{string(e.Type), e.Text}
+		}
+		tr["textDiff"] = edits
+	}
+	return tr
+}
+
 // Deltas is a sortable slice of changes
 type Deltas []*Delta
 
@@ -179,6 +213,7 @@ var opOrder = map[Operation]uint{
 	DTContext: 1,
 	DTInsert:  2,
 	DTUpdate:  3,
+	DTRename:  4,
 }
 
 // Less returns true if the value at index i is a smaller sort quantity than
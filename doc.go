@@ -19,9 +19,13 @@
 //   []interface{}
 // and five scalar types:
 //   string, int, float64, bool, nil
+// byte slices ([]byte) are also compared as scalar values
 //
 // by operating on native go types deepdiff can compare documents encoded in different
-// formats, for example decoded CSV or CBOR.
+// formats, for example decoded CSV or CBOR. DecodeCSV & DecodeNDJSON read CSV &
+// newline-delimited JSON into these types, and DiffCSV & DiffNDJSON diff them
+// directly from readers. DecodeCBOR & DiffCBOR do the same for CBOR, keeping
+// byte strings & integers intact.
 //
 // deepdiff is based off an algorithm designed for diffing XML documents outlined in:
 // Detecting Changes in XML Documents by Grégory Cobéna & Amélie Marian
@@ -29,5 +33,13 @@
 // it's been adapted to fit purposes of diffing for Qri: https://github.com/qri-io/qri
 // the guiding use case for this work
 //
+// XML documents can be diffed with DiffXML, which maps elements, attributes &
+// text onto objects & arrays with DecodeXML. FormatPrettyXML reports changes
+// by XPath
+//
 // deepdiff also includes a tool for applying patches, see documentation for details
+//
+// Deltas can be stored compactly with MarshalBinary, or as a stream of delta
+// scripts with DeltaEncoder & DeltaDecoder, which share one table of keys
+// across every script in the stream
 package deepdiff
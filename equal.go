@@ -0,0 +1,91 @@
+package deepdiff
+
+import "reflect"
+
+// nodesEqual checks two trees for structural equality without relying on
+// hashes, confirming a hash match isn't a collision
+func nodesEqual(a, b node) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch x := a.(type) {
+	case *scalar:
+		y := b.(*scalar)
+		if x.keyed || y.keyed {
+			return x.keyed && y.keyed && x.key == y.key
+		}
+		return reflect.DeepEqual(x.value, y.value)
+	case *array:
+		y := b.(*array)
+		if x.mode != ArrayOrdered || y.mode != ArrayOrdered {
+			return unorderedEqual(x, y)
+		}
+		if len(x.children) != len(y.children) {
+			return false
+		}
+		for i, ch := range x.children {
+			if !nodesEqual(ch, y.children[i]) {
+				return false
+			}
+		}
+		return true
+	case *object:
+		y := b.(*object)
+		if len(x.children) != len(y.children) {
+			return false
+		}
+		// children are sorted by address
+		for i, ch := range x.children {
+			ych := y.children[i]
+			if !ch.Addr().Eq(ych.Addr()) || !nodesEqual(ch, ych) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// unorderedEqual compares array elements ignoring order. Elements are
+// bucketed by hash & confirmed with nodesEqual. Sets ignore repetition
+func unorderedEqual(a, b *array) bool {
+	mode := a.mode
+	if mode == ArrayOrdered {
+		mode = b.mode
+	}
+	if mode == ArrayMultiset && len(a.children) != len(b.children) {
+		return false
+	}
+	return containsAll(a.children, b.children, mode) &&
+		(mode == ArrayMultiset || containsAll(b.children, a.children, mode))
+}
+
+// containsAll checks every node in needles has an equal node in haystack.
+// In multiset mode each haystack node can only be used once
+func containsAll(needles, haystack []node, mode ArrayMode) bool {
+	byHash := map[string][]node{}
+	for _, n := range haystack {
+		key := hashStr(n.Hash())
+		byHash[key] = append(byHash[key], n)
+	}
+
+	for _, n := range needles {
+		key := hashStr(n.Hash())
+		candidates := byHash[key]
+		found := -1
+		for i, can := range candidates {
+			if nodesEqual(n, can) {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			return false
+		}
+		if mode == ArrayMultiset {
+			byHash[key] = append(candidates[:found:found], candidates[found+1:]...)
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package deepdiff
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"hash"
+	"hash/fnv"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// collidingHash is a hash function that returns the same sum for every input
+type collidingHash struct{}
+
+func (collidingHash) Write(p []byte) (int, error) { return len(p), nil }
+func (collidingHash) Sum(b []byte) []byte         { return append(b, 0) }
+func (collidingHash) Reset()                      {}
+func (collidingHash) Size() int                   { return 1 }
+func (collidingHash) BlockSize() int              { return 1 }
+
+func newCollidingHash() hash.Hash { return collidingHash{} }
+
+func TestVerifyMatches(t *testing.T) {
+	cases := []TestCase{
+		{"scalar update", `{"a":1,"b":2}`, `{"a":1,"b":3}`, nil},
+		{"array insert", `[1,2,3]`, `[1,4,2,3]`, nil},
+		{"nested objects", `{"a":{"b":[true,false]},"c":"d"}`, `{"a":{"b":[true,false]},"c":"e","f":"g"}`, nil},
+	}
+
+	// every subtree collides, so without verification matches are arbitrary
+	for _, c := range cases {
+		var src, dst interface{}
+		if err := json.Unmarshal([]byte(c.src), &src); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(c.dst), &dst); err != nil {
+			t.Fatal(err)
+		}
+
+		dd := New(func(cfg *Config) {
+			cfg.NewHash = newCollidingHash
+			cfg.VerifyMatches = true
+		})
+		deltas, err := dd.Diff(context.Background(), src, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Patch(deltas, &src); err != nil {
+			t.Fatalf("%s: patch error: %s", c.description, err)
+		}
+		if !reflect.DeepEqual(src, dst) {
+			t.Errorf("%s: patched result mismatch", c.description)
+		}
+	}
+}
+
+func TestPerDiffHash(t *testing.T) {
+	var src, dst interface{}
+	if err := json.Unmarshal([]byte(`{"a":[1,2,3],"b":{"c":"d"},"e":true}`), &src); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`{"a":[1,3],"b":{"c":"f"},"e":true}`), &dst); err != nil {
+		t.Fatal(err)
+	}
+
+	expect, err := New().Diff(context.Background(), src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectJSON, _ := json.Marshal(expect)
+
+	// diffs with different hash functions can safely run concurrently
+	hashes := []func() hash.Hash{sha256.New, fnv.New128a, NewHash}
+	wg := sync.WaitGroup{}
+	for _, newHash := range hashes {
+		wg.Add(1)
+		go func(newHash func() hash.Hash) {
+			defer wg.Done()
+			dd := New(func(cfg *Config) { cfg.NewHash = newHash })
+			for i := 0; i < 10; i++ {
+				deltas, err := dd.Diff(context.Background(), src, dst)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if gotJSON, _ := json.Marshal(deltas); string(gotJSON) != string(expectJSON) {
+					t.Errorf("result mismatch.\nwant: %s\ngot:  %s", expectJSON, gotJSON)
+					return
+				}
+			}
+		}(newHash)
+	}
+	wg.Wait()
+}
+
+func TestNodesEqual(t *testing.T) {
+	cases := []struct {
+		a, b   string
+		modes  map[string]ArrayMode
+		expect bool
+	}{
+		{`1`, `1`, nil, true},
+		{`1`, `"1"`, nil, false},
+		{`[1,2]`, `[1,2]`, nil, true},
+		{`[1,2]`, `[2,1]`, nil, false},
+		{`[1,2]`, `[2,1]`, map[string]ArrayMode{"/": ArrayMultiset}, true},
+		{`[1,1,2]`, `[2,1]`, map[string]ArrayMode{"/": ArrayMultiset}, false},
+		{`[1,1,2]`, `[2,1]`, map[string]ArrayMode{"/": ArraySet}, true},
+		{`{"a":[1],"b":null}`, `{"b":null,"a":[1]}`, nil, true},
+		{`{"a":[1]}`, `{"a":[1],"b":null}`, nil, false},
+	}
+
+	for i, c := range cases {
+		var a, b interface{}
+		if err := json.Unmarshal([]byte(c.a), &a); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(c.b), &b); err != nil {
+			t.Fatal(err)
+		}
+		d := New(func(cfg *Config) {
+			cfg.NewHash = newCollidingHash
+			cfg.ArrayModes = c.modes
+		}).newDiff(a, b)
+		na := d.tree(a, RootAddr{}, nil, newArena(d.newHash))
+		nb := d.tree(b, RootAddr{}, nil, newArena(d.newHash))
+		if got := nodesEqual(na, nb); got != c.expect {
+			t.Errorf("case %d: %s == %s expected %t, got %t", i, c.a, c.b, c.expect, got)
+		}
+	}
+}
@@ -2,103 +2,491 @@ package deepdiff
 
 import (
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
+// FormatConfig are any possible configuration parameters for formatting
+// deltas & stats
+type FormatConfig struct {
+	// Styler wraps each piece of formatted output in markers for the operation
+	// it describes. A nil Styler writes unstyled output
+	Styler Styler
+	// MaxValueLength caps the length of a rendered value. Objects & arrays that
+	// render longer are replaced with a summary, long strings are truncated.
+	// 0 means no limit
+	MaxValueLength int
+	// MaxArrayElements caps the number of array elements shown. Remaining
+	// elements are summarized. 0 means no limit
+	MaxArrayElements int
+	// MaxDepth caps how deeply nested values are shown. Objects & arrays nested
+	// at MaxDepth or deeper are summarized. 0 means no limit
+	MaxDepth int
+}
+
+// FormatOption is a function that adjusts a format config, zero or more
+// FormatOptions can be passed to format functions
+type FormatOption func(cfg *FormatConfig)
+
+func newFormatConfig(colorTTY bool, opts []FormatOption) *FormatConfig {
+	cfg := &FormatConfig{}
+	if colorTTY {
+		cfg.Styler = TTYTheme()
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.Styler == nil {
+		cfg.Styler = Theme{}
+	}
+	return cfg
+}
+
+// Styler produces the start & end markers that surround formatted output for
+// an operation. Terminal color escapes are one kind of styling, but any text
+// target that can delimit a span (HTML tags, markdown, etc.) can be supported
+// with a Styler
+type Styler interface {
+	// Start returns the marker written before output for op
+	Start(op Operation) string
+	// End returns the marker written after output for op
+	End(op Operation) string
+}
+
+// Style is a pair of markers that open & close a span of styled output
+type Style struct {
+	Start string
+	End   string
+}
+
+// Theme is a Styler that maps operations to styles. Operations a theme doesn't
+// define are written without styling
+type Theme map[Operation]Style
+
+// Start returns the opening marker for op
+func (t Theme) Start(op Operation) string { return t[op].Start }
+
+// End returns the closing marker for op
+func (t Theme) End(op Operation) string { return t[op].End }
+
+// ansiReset is the terminal escape that ends any color span
+const ansiReset = "\x1b[0m"
+
+// ANSIStyle creates a style from one of the 16 standard terminal colors, where
+// code is the SGR foreground code, eg: 31 for red
+func ANSIStyle(code int) Style {
+	return Style{Start: fmt.Sprintf("\x1b[%dm", code), End: ansiReset}
+}
+
+// ANSI256Style creates a style from the 256 color terminal palette
+func ANSI256Style(code uint8) Style {
+	return Style{Start: fmt.Sprintf("\x1b[38;5;%dm", code), End: ansiReset}
+}
+
+// TrueColorStyle creates a style from a 24-bit RGB color, supported by most
+// modern terminals
+func TrueColorStyle(r, g, b uint8) Style {
+	return Style{Start: fmt.Sprintf("\x1b[38;2;%d;%d;%dm", r, g, b), End: ansiReset}
+}
+
+// TTYTheme is the default terminal theme:
+// red "-" for deletions
+// green "+" for insertions
+// blue "~" for changes
+// cyan "^" for renames
+func TTYTheme() Theme {
+	return Theme{
+		DTContext: ANSIStyle(37), // netural
+		DTInsert:  ANSIStyle(32), // green
+		DTDelete:  ANSIStyle(31), // red
+		DTUpdate:  ANSIStyle(34), // blue
+		DTRename:  ANSIStyle(36), // cyan
+	}
+}
+
+// ColorblindTheme is a 256 color terminal theme that avoids pairing red &
+// green, using colors from the Okabe-Ito palette that remain distinguishable
+// under the common forms of color blindness
+func ColorblindTheme() Theme {
+	return Theme{
+		DTContext: ANSI256Style(250), // light grey
+		DTInsert:  ANSI256Style(32),  // blue
+		DTDelete:  ANSI256Style(208), // orange
+		DTUpdate:  ANSI256Style(175), // reddish purple
+		DTRename:  ANSI256Style(220), // yellow
+	}
+}
+
 // FormatPrettyString is a convenience wrapper that outputs to a string instead
 // of an io.Writer
-func FormatPrettyString(changes Deltas, colorTTY bool) (string, error) {
+func FormatPrettyString(changes Deltas, colorTTY bool, opts ...FormatOption) (string, error) {
 	buf := &bytes.Buffer{}
-	if err := FormatPretty(buf, changes, colorTTY); err != nil {
+	if err := FormatPretty(buf, changes, colorTTY, opts...); err != nil {
 		return "", err
 	}
 	return buf.String(), nil
 }
 
-// FormatPretty writes a text report to w. if colorTTY is true it will add
-// red "-" for deletions
-// green "+" for insertions
-// blue "~" for changes (an insert & delete at the same path)
+// FormatPretty writes a text report to w. if colorTTY is true output is
+// styled with TTYTheme. A Styler set with options takes precedence over
+// colorTTY
 // This is very much a work in progress
-func FormatPretty(w io.Writer, changes Deltas, colorTTY bool) error {
-	var colorMap map[Operation]string
+func FormatPretty(w io.Writer, changes Deltas, colorTTY bool, opts ...FormatOption) error {
+	return formatPretty(w, changes, 0, newFormatConfig(colorTTY, opts))
+}
 
-	if colorTTY {
-		colorMap = ttyColorMap()
+func formatPretty(w io.Writer, changes Deltas, indent int, cfg *FormatConfig) error {
+	for _, d := range changes {
+		dataStr := ""
+		if len(d.TextDiff) > 0 {
+			str, err := cfg.renderTextDiff(d.TextDiff, d.Type)
+			if err != nil {
+				return err
+			}
+			dataStr = str
+		} else if d.Value != nil {
+			d, err := cfg.renderValue(d.Value)
+			if err != nil {
+				return err
+			}
+			dataStr = string(d)
+		}
+		path := d.Path.String()
+		if d.Type == DTRename {
+			path = fmt.Sprintf("%s -> %s", d.SourcePath, path)
+		}
+		fmt.Fprintf(w, "%s%s%s%s: %s%s\n", strings.Repeat("  ", indent), cfg.Styler.Start(d.Type), d.Type, path, dataStr, cfg.Styler.End(d.Type))
+		if len(d.Deltas) > 0 {
+			if err := formatPretty(w, d.Deltas, indent+1, cfg); err != nil {
+				return err
+			}
+		}
 	}
 
-	return formatPretty(w, changes, 0, colorMap)
+	return nil
 }
 
-func ttyColorMap() map[Operation]string {
-	return map[Operation]string{
-		Operation("close"): "\x1b[0m", // end color tag
+// renderTextDiff writes a text diff as a quoted string, marking deleted spans
+// [-like this-] and inserted spans {+like this+}. op is the operation of the
+// delta the text diff belongs to, styling for op is closed around each marked
+// span & reopened after it. Context runs longer than MaxValueLength are cut,
+// keeping the text closest to the changes around them
+func (cfg *FormatConfig) renderTextDiff(td TextDiff, op Operation) (string, error) {
+	buf := &strings.Builder{}
+	buf.WriteByte('"')
+	for i, e := range td {
+		text := e.Text
+		if e.Type == DTContext {
+			text = shortenContext(text, cfg.MaxValueLength, i == 0, i == len(td)-1)
+		}
+		data, err := json.Marshal(text)
+		if err != nil {
+			return "", err
+		}
+		text = string(data[1 : len(data)-1])
 
-		DTContext: "\x1b[37m", // netural
-		DTInsert:  "\x1b[32m", // green
-		DTDelete:  "\x1b[31m", // red
-		DTUpdate:  "\x1b[34m", // blue
+		switch e.Type {
+		case DTDelete:
+			fmt.Fprintf(buf, "%s%s[-%s-]%s%s", cfg.Styler.End(op), cfg.Styler.Start(DTDelete), text, cfg.Styler.End(DTDelete), cfg.Styler.Start(op))
+		case DTInsert:
+			fmt.Fprintf(buf, "%s%s{+%s+}%s%s", cfg.Styler.End(op), cfg.Styler.Start(DTInsert), text, cfg.Styler.End(DTInsert), cfg.Styler.Start(op))
+		default:
+			buf.WriteString(text)
+		}
 	}
+	buf.WriteByte('"')
+	return buf.String(), nil
 }
 
-func formatPretty(w io.Writer, changes Deltas, indent int, colorMap map[Operation]string) error {
-	for _, d := range changes {
-		dataStr := ""
-		if d.Value != nil {
-			d, err := json.Marshal(d.Value)
+// shortenContext cuts a context run of a text diff to about max bytes. The
+// first run keeps its end & the last run keeps its start, runs between changes
+// keep both ends. 0 means no limit
+func shortenContext(text string, max int, first, last bool) string {
+	if max <= 0 || len(text) <= max {
+		return text
+	}
+	switch {
+	case last:
+		return truncateString(text, max)
+	case first:
+		return "..." + text[runeStart(text, len(text)-max):]
+	}
+	return truncateString(text, max/2) + text[runeStart(text, len(text)-max/2):]
+}
+
+// runeStart finds the start of the first character at or after byte i of s
+func runeStart(s string, i int) int {
+	for i < len(s) && !utf8.RuneStart(s[i]) {
+		i++
+	}
+	return i
+}
+
+// FormatJSON writes changes to w in the compact form produced by
+// Delta.MarshalJSON, applying any value limits set with opts. Summaries of
+// oversized values are written as strings
+func FormatJSON(w io.Writer, changes Deltas, opts ...FormatOption) error {
+	cfg := newFormatConfig(false, opts)
+	return json.NewEncoder(w).Encode(cfg.summarizeDeltas(changes))
+}
+
+// summary is a short description standing in for a value too large to show
+type summary string
+
+// MarshalJSON encodes a summary as a JSON string
+func (s summary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(s))
+}
+
+func arraySummary(length int) summary {
+	return summary(fmt.Sprintf("[... %d items]", length))
+}
+
+func objectSummary(length int) summary {
+	return summary(fmt.Sprintf("{... %d keys}", length))
+}
+
+// limited returns true if any value limits are set
+func (cfg *FormatConfig) limited() bool {
+	return cfg.MaxValueLength > 0 || cfg.MaxArrayElements > 0 || cfg.MaxDepth > 0
+}
+
+// renderValue writes v as text for pretty formatting. summaries are written
+// without quotes, and byte strings are written in hex as h'616263', so they
+// can't be mistaken for text
+func (cfg *FormatConfig) renderValue(v interface{}) ([]byte, error) {
+	if cfg.limited() {
+		v = cfg.summarize(v, 0)
+	}
+	buf := &bytes.Buffer{}
+	err := writeSummarized(buf, v)
+	return buf.Bytes(), err
+}
+
+// bytesString writes a byte string in hex as h'616263'
+func bytesString(b []byte) string {
+	return "h'" + hex.EncodeToString(b) + "'"
+}
+
+func writeSummarized(buf *bytes.Buffer, v interface{}) error {
+	switch x := v.(type) {
+	case summary:
+		buf.WriteString(string(x))
+	case []byte:
+		buf.WriteString(bytesString(x))
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			data, err := json.Marshal(key)
 			if err != nil {
 				return err
 			}
-			dataStr = string(d)
+			buf.Write(data)
+			buf.WriteByte(':')
+			if err := writeSummarized(buf, x[key]); err != nil {
+				return err
+			}
 		}
-		fmt.Fprintf(w, "%s%s%s%s: %s%s\n", strings.Repeat("  ", indent), colorMap[d.Type], d.Type, d.Path, dataStr, colorMap[Operation("close")])
-		if len(d.Deltas) > 0 {
-			if err := formatPretty(w, d.Deltas, indent+1, colorMap); err != nil {
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, el := range x {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeSummarized(buf, el); err != nil {
 				return err
 			}
 		}
+		buf.WriteByte(']')
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
 	}
-
 	return nil
 }
 
+// summarizeDeltas returns a copy of changes with value limits applied
+func (cfg *FormatConfig) summarizeDeltas(changes Deltas) Deltas {
+	if !cfg.limited() {
+		return changes
+	}
+	sum := make(Deltas, len(changes))
+	for i, d := range changes {
+		cp := *d
+		cp.Value = cfg.summarize(d.Value, 0)
+		cp.SourceValue = cfg.summarize(d.SourceValue, 0)
+		cp.Deltas = cfg.summarizeDeltas(d.Deltas)
+		sum[i] = &cp
+	}
+	return sum
+}
+
+// summarize returns v with value limits applied, replacing any oversized
+// portions of v with summaries. v itself is not modified
+func (cfg *FormatConfig) summarize(v interface{}, depth int) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		// every key renders as at least two characters
+		if (cfg.MaxDepth > 0 && depth >= cfg.MaxDepth) || (cfg.MaxValueLength > 0 && len(x)*2 > cfg.MaxValueLength) {
+			return objectSummary(len(x))
+		}
+		obj := make(map[string]interface{}, len(x))
+		for key, val := range x {
+			obj[key] = cfg.summarize(val, depth+1)
+		}
+		if cfg.MaxValueLength > 0 && !fitsLength(obj, cfg.MaxValueLength) {
+			return objectSummary(len(x))
+		}
+		return obj
+	case []interface{}:
+		shown := len(x)
+		if cfg.MaxArrayElements > 0 && shown > cfg.MaxArrayElements {
+			shown = cfg.MaxArrayElements
+		}
+		// every element renders as at least two characters
+		if (cfg.MaxDepth > 0 && depth >= cfg.MaxDepth) || (cfg.MaxValueLength > 0 && shown*2 > cfg.MaxValueLength) {
+			return arraySummary(len(x))
+		}
+		arr := make([]interface{}, shown, shown+1)
+		for i := range arr {
+			arr[i] = cfg.summarize(x[i], depth+1)
+		}
+		if shown < len(x) {
+			arr = append(arr, summary(fmt.Sprintf("... %d more items", len(x)-shown)))
+		}
+		if cfg.MaxValueLength > 0 && !fitsLength(arr, cfg.MaxValueLength) {
+			return arraySummary(len(x))
+		}
+		return arr
+	case string:
+		if cfg.MaxValueLength > 0 && !fitsLength(x, cfg.MaxValueLength) {
+			return truncateString(x, cfg.MaxValueLength-len(`"..."`))
+		}
+	case []byte:
+		if cfg.MaxValueLength > 0 && !fitsLength(x, cfg.MaxValueLength) {
+			shown := (cfg.MaxValueLength - len("h'...'")) / 2
+			if shown < 0 {
+				shown = 0
+			}
+			return summary("h'" + hex.EncodeToString(x[:shown]) + "...'")
+		}
+	}
+	return v
+}
+
+// truncateString cuts s to at most max bytes without splitting a character,
+// and adds an ellipsis
+func truncateString(s string, max int) string {
+	if max < 0 {
+		max = 0
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max] + "..."
+}
+
+// fitsLength returns true if the rendered length of v is no more than max.
+// Rendering is estimated, and stops as soon as max is exceeded
+func fitsLength(v interface{}, max int) bool {
+	return renderedLength(v, max) <= max
+}
+
+func renderedLength(v interface{}, budget int) (length int) {
+	switch x := v.(type) {
+	case summary:
+		return len(x)
+	case string:
+		return len(x) + 2
+	case []byte:
+		return len(x)*2 + len("h''")
+	case map[string]interface{}:
+		length = 1
+		for key, val := range x {
+			length += len(key) + 4
+			if length > budget {
+				return length
+			}
+			length += renderedLength(val, budget-length)
+			if length > budget {
+				return length
+			}
+		}
+		return length
+	case []interface{}:
+		length = 1
+		for _, el := range x {
+			length += renderedLength(el, budget-length) + 1
+			if length > budget {
+				return length
+			}
+		}
+		return length
+	case nil:
+		return len("null")
+	case bool:
+		if x {
+			return len("true")
+		}
+		return len("false")
+	case float64:
+		return len(strconv.FormatFloat(x, 'g', -1, 64))
+	case int64:
+		return len(strconv.FormatInt(x, 10))
+	case int:
+		return len(strconv.Itoa(x))
+	}
+	data, _ := json.Marshal(v)
+	return len(data)
+}
+
 // FormatPrettyStatsString prints a string of stats info
-func FormatPrettyStatsString(diffStat *Stats, colorTTY bool) string {
+func FormatPrettyStatsString(diffStat *Stats, colorTTY bool, opts ...FormatOption) string {
 	buf := &bytes.Buffer{}
-	FormatPrettyStats(buf, diffStat, colorTTY)
+	FormatPrettyStats(buf, diffStat, colorTTY, opts...)
 	return buf.String()
 }
 
 // FormatPrettyStats writes stats info to a supplied writer destination,
-// optionally adding terminal color tags
-func FormatPrettyStats(w io.Writer, diffStat *Stats, colorTTY bool) {
-	var colorMap map[Operation]string
-
-	if colorTTY {
-		colorMap = ttyColorMap()
-	}
-
-	formatStats(w, diffStat, colorMap)
+// optionally adding terminal color tags. A Styler set with options takes
+// precedence over colorTTY
+func FormatPrettyStats(w io.Writer, diffStat *Stats, colorTTY bool, opts ...FormatOption) {
+	formatStats(w, diffStat, newFormatConfig(colorTTY, opts).Styler)
 }
 
-func formatStats(w io.Writer, ds *Stats, colorMap map[Operation]string) {
+func formatStats(w io.Writer, ds *Stats, s Styler) {
 	if ds == nil {
 		return
 	}
-	closeColor := colorMap[Operation("close")]
 
-	elsColor := colorMap[DTInsert]
+	elsOp := DTInsert
 	change := ds.NodeChange()
 	elementsWord := "elements"
 	sign := "+"
 	if change < 0 {
-		elsColor = colorMap[DTDelete]
+		elsOp = DTDelete
 		sign = ""
 	} else if change == 0 {
-		elsColor = colorMap[DTContext]
+		elsOp = DTContext
 		sign = ""
 	}
 	if change == 1 || change == -1 {
@@ -106,28 +494,36 @@ func formatStats(w io.Writer, ds *Stats, colorMap map[Operation]string) {
 	}
 
 	fmt.Fprintf(w, "%s%s%d %s%s%s%s.",
-		elsColor, sign, change, closeColor,
-		colorMap[DTContext], elementsWord, closeColor,
+		s.Start(elsOp), sign, change, s.End(elsOp),
+		s.Start(DTContext), elementsWord, s.End(DTContext),
 	)
 
 	insertsWord := "inserts"
 	if ds.Inserts == 1 {
 		insertsWord = "insert"
 	}
-	fmt.Fprintf(w, " %s%d %s.%s", colorMap[DTInsert], ds.Inserts, insertsWord, closeColor)
+	fmt.Fprintf(w, " %s%d %s.%s", s.Start(DTInsert), ds.Inserts, insertsWord, s.End(DTInsert))
 
 	deletesWord := "deletes"
 	if ds.Deletes == 1 {
 		deletesWord = "delete"
 	}
-	fmt.Fprintf(w, " %s%d %s.%s", colorMap[DTDelete], ds.Deletes, deletesWord, closeColor)
+	fmt.Fprintf(w, " %s%d %s.%s", s.Start(DTDelete), ds.Deletes, deletesWord, s.End(DTDelete))
 
 	if ds.Updates > 0 {
 		updatesWord := "updates"
 		if ds.Updates == 1 {
 			updatesWord = "update"
 		}
-		fmt.Fprintf(w, " %s%d %s.%s", colorMap[DTUpdate], ds.Updates, updatesWord, closeColor)
+		fmt.Fprintf(w, " %s%d %s.%s", s.Start(DTUpdate), ds.Updates, updatesWord, s.End(DTUpdate))
+	}
+
+	if ds.Renames > 0 {
+		renamesWord := "renames"
+		if ds.Renames == 1 {
+			renamesWord = "rename"
+		}
+		fmt.Fprintf(w, " %s%d %s.%s", s.Start(DTRename), ds.Renames, renamesWord, s.End(DTRename))
 	}
 	fmt.Fprintf(w, "\n")
 }
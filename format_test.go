@@ -1,6 +1,10 @@
 package deepdiff
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
 
 func TestFormatPretty(t *testing.T) {
 	patch := Deltas{
@@ -31,6 +35,10 @@ func TestFormatStatsPretty(t *testing.T) {
 			&Stats{Left: 2, Right: 1, Inserts: 1, Updates: 1, Deletes: 1},
 			"-1 element. 1 insert. 1 delete. 1 update.\n",
 		},
+		{"renames",
+			&Stats{Left: 3, Right: 3, Inserts: 1, Deletes: 1, Renames: 2},
+			"0 elements. 1 insert. 1 delete. 2 renames.\n",
+		},
 	}
 
 	for i, c := range cases {
@@ -48,3 +56,283 @@ func TestFormatStatsNull(t *testing.T) {
 		t.Errorf("want:\n%s\ngot:\n%s", expect, got)
 	}
 }
+
+// htmlStyler demonstrates styling for a non-terminal target
+type htmlStyler struct{}
+
+func (htmlStyler) Start(op Operation) string {
+	switch op {
+	case DTInsert:
+		return "<ins>"
+	case DTDelete:
+		return "<del>"
+	}
+	return ""
+}
+
+func (htmlStyler) End(op Operation) string {
+	switch op {
+	case DTInsert:
+		return "</ins>"
+	case DTDelete:
+		return "</del>"
+	}
+	return ""
+}
+
+func TestFormatPrettyStyler(t *testing.T) {
+	patch := Deltas{
+		{Type: DTContext, Path: StringAddr("a"), Value: 1},
+		{Type: DTDelete, Path: StringAddr("b"), Value: 2},
+		{Type: DTInsert, Path: StringAddr("b"), Value: 3},
+	}
+
+	cases := []struct {
+		description string
+		colorTTY    bool
+		opts        []FormatOption
+		expect      string
+	}{
+		{"no color", false, nil,
+			" a: 1\n-b: 2\n+b: 3\n",
+		},
+		{"tty theme", true, nil,
+			"\x1b[37m a: 1\x1b[0m\n\x1b[31m-b: 2\x1b[0m\n\x1b[32m+b: 3\x1b[0m\n",
+		},
+		{"custom theme overrides colorTTY", true,
+			[]FormatOption{func(cfg *FormatConfig) {
+				cfg.Styler = Theme{DTInsert: {Start: "{", End: "}"}}
+			}},
+			" a: 1\n-b: 2\n{+b: 3}\n",
+		},
+		{"html styler", false,
+			[]FormatOption{func(cfg *FormatConfig) { cfg.Styler = htmlStyler{} }},
+			" a: 1\n<del>-b: 2</del>\n<ins>+b: 3</ins>\n",
+		},
+		{"256 color style", false,
+			[]FormatOption{func(cfg *FormatConfig) {
+				cfg.Styler = Theme{DTDelete: ANSI256Style(208)}
+			}},
+			" a: 1\n\x1b[38;5;208m-b: 2\x1b[0m\n+b: 3\n",
+		},
+	}
+
+	for i, c := range cases {
+		got, err := FormatPrettyString(patch, c.colorTTY, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.expect {
+			t.Errorf("%d %s\nwant:\n%q\ngot:\n%q", i, c.description, c.expect, got)
+		}
+	}
+}
+
+func TestFormatStatsStyler(t *testing.T) {
+	stats := &Stats{Left: 2, Right: 3, Inserts: 1}
+	got := FormatPrettyStatsString(stats, false, func(cfg *FormatConfig) {
+		cfg.Styler = Theme{
+			DTInsert:  {Start: "[", End: "]"},
+			DTContext: TrueColorStyle(1, 2, 3),
+		}
+	})
+	expect := "[+1 ]\x1b[38;2;1;2;3melement\x1b[0m. [1 insert.] 0 deletes.\n"
+	if got != expect {
+		t.Errorf("want:\n%q\ngot:\n%q", expect, got)
+	}
+}
+
+func TestFormatValueLimits(t *testing.T) {
+	bigArray := make([]interface{}, 10234)
+	for i := range bigArray {
+		bigArray[i] = float64(i)
+	}
+	patch := Deltas{
+		{Type: DTInsert, Path: StringAddr("a"), Value: bigArray},
+		{Type: DTInsert, Path: StringAddr("b"), Value: map[string]interface{}{
+			"c": map[string]interface{}{"d": true, "e": false},
+			"f": "apples-and-oranges",
+		}},
+		{Type: DTInsert, Path: StringAddr("g"), Value: "thirty-thousand-something-dogecoin"},
+	}
+
+	cases := []struct {
+		description string
+		opt         FormatOption
+		expect      string
+	}{
+		{"max array elements",
+			func(cfg *FormatConfig) { cfg.MaxArrayElements = 3 },
+			`+a: [0,1,2,... 10231 more items]
++b: {"c":{"d":true,"e":false},"f":"apples-and-oranges"}
++g: "thirty-thousand-something-dogecoin"
+`,
+		},
+		{"max depth",
+			func(cfg *FormatConfig) {
+				cfg.MaxDepth = 1
+				cfg.MaxArrayElements = 2
+			},
+			`+a: [0,1,... 10232 more items]
++b: {"c":{... 2 keys},"f":"apples-and-oranges"}
++g: "thirty-thousand-something-dogecoin"
+`,
+		},
+		{"max value length",
+			func(cfg *FormatConfig) { cfg.MaxValueLength = 20 },
+			`+a: [... 10234 items]
++b: {... 2 keys}
++g: "thirty-thousand..."
+`,
+		},
+		{"max value length keeps short values",
+			func(cfg *FormatConfig) { cfg.MaxValueLength = 60 },
+			`+a: [... 10234 items]
++b: {"c":{"d":true,"e":false},"f":"apples-and-oranges"}
++g: "thirty-thousand-something-dogecoin"
+`,
+		},
+	}
+
+	for i, c := range cases {
+		got, err := FormatPrettyString(patch, false, c.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.expect {
+			t.Errorf("%d %s\nwant:\n%s\ngot:\n%s", i, c.description, c.expect, got)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	patch := Deltas{
+		{Type: DTUpdate, Path: StringAddr("a"), SourceValue: []byte("abc"), Value: []byte("abd")},
+		{Type: DTUpdate, Path: StringAddr("b"), SourceValue: "YWJj", Value: "YWJk"},
+		{Type: DTInsert, Path: StringAddr("c"), Value: []interface{}{[]byte{0, 0xff}, map[string]interface{}{"d": []byte{}}}},
+		{Type: DTInsert, Path: StringAddr("e"), Value: []byte("a long byte string")},
+	}
+
+	got, err := FormatPrettyString(patch, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `~a: h'616264'
+~b: "YWJk"
++c: [h'00ff',{"d":h''}]
++e: h'61206c6f6e67206279746520737472696e67'
+`
+	if got != expect {
+		t.Errorf("want:\n%s\ngot:\n%s", expect, got)
+	}
+
+	got, err = FormatPrettyString(patch, false, func(cfg *FormatConfig) { cfg.MaxValueLength = 16 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = `~a: h'616264'
+~b: "YWJk"
++c: [... 2 items]
++e: h'61206c6f6e...'
+`
+	if got != expect {
+		t.Errorf("want:\n%s\ngot:\n%s", expect, got)
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	patch := Deltas{
+		{Type: DTContext, Path: StringAddr("a"), Deltas: Deltas{
+			{Type: DTInsert, Path: IndexAddr(0), Value: []interface{}{"a", "b", "c"}},
+		}},
+		{Type: DTDelete, Path: StringAddr("b"), Value: map[string]interface{}{"c": []interface{}{true}}},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := FormatJSON(buf, patch, func(cfg *FormatConfig) {
+		cfg.MaxArrayElements = 1
+		cfg.MaxDepth = 1
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expect := `[[" ","a",null,[["+",0,["a","... 2 more items"]]]],["-","b",{"c":"[... 1 items]"}]]` + "\n"
+	if got := buf.String(); got != expect {
+		t.Errorf("want:\n%s\ngot:\n%s", expect, got)
+	}
+
+	// no limits matches MarshalJSON output
+	buf.Reset()
+	if err := FormatJSON(buf, patch); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != string(data)+"\n" {
+		t.Errorf("want:\n%s\ngot:\n%s", string(data), got)
+	}
+}
+
+func TestFormatPrettyTextDiff(t *testing.T) {
+	patch := Deltas{
+		{Type: DTUpdate, Path: StringAddr("a"), SourceValue: "the quick fox", Value: "the slow fox", TextDiff: TextDiff{
+			{Type: DTContext, Text: "the "},
+			{Type: DTDelete, Text: "quick"},
+			{Type: DTInsert, Text: "slow"},
+			{Type: DTContext, Text: " \"fox\""},
+		}},
+	}
+
+	got, err := FormatPrettyString(patch, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "~a: \"the [-quick-]{+slow+} \\\"fox\\\"\"\n"
+	if got != expect {
+		t.Errorf("want:\n%q\ngot:\n%q", expect, got)
+	}
+
+	got, err = FormatPrettyString(patch, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = "\x1b[34m~a: \"the \x1b[0m\x1b[31m[-quick-]\x1b[0m\x1b[34m\x1b[0m\x1b[32m{+slow+}\x1b[0m\x1b[34m \\\"fox\\\"\"\x1b[0m\n"
+	if got != expect {
+		t.Errorf("want:\n%q\ngot:\n%q", expect, got)
+	}
+
+	// markup stays balanced with stylers that nest spans
+	html := Theme{
+		DTUpdate: Style{Start: "<u>", End: "</u>"},
+		DTDelete: Style{Start: "<del>", End: "</del>"},
+		DTInsert: Style{Start: "<ins>", End: "</ins>"},
+	}
+	got, err = FormatPrettyString(patch, false, func(cfg *FormatConfig) { cfg.Styler = html })
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = "<u>~a: \"the </u><del>[-quick-]</del><u></u><ins>{+slow+}</ins><u> \\\"fox\\\"\"</u>\n"
+	if got != expect {
+		t.Errorf("want:\n%q\ngot:\n%q", expect, got)
+	}
+
+	// long context runs are cut to the value length limit
+	patch = Deltas{
+		{Type: DTUpdate, Path: StringAddr("a"), TextDiff: TextDiff{
+			{Type: DTContext, Text: "one two three "},
+			{Type: DTDelete, Text: "four"},
+			{Type: DTContext, Text: " five six seven eight "},
+			{Type: DTInsert, Text: "nine"},
+			{Type: DTContext, Text: " ten eleven twelve"},
+		}},
+	}
+	got, err = FormatPrettyString(patch, false, func(cfg *FormatConfig) { cfg.MaxValueLength = 10 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = "~a: \"...two three [-four-] five...ight {+nine+} ten eleve...\"\n"
+	if got != expect {
+		t.Errorf("want:\n%q\ngot:\n%q", expect, got)
+	}
+}
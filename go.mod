@@ -1,5 +1,5 @@
 module github.com/qri-io/deepdiff
 
-go 1.12
+go 1.14
 
 require github.com/google/go-cmp v0.3.1
@@ -0,0 +1,273 @@
+package deepdiff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+)
+
+// jsonSource is a JSON document trees are built from. Compound nodes built
+// from JSON hold their value as a range of the document instead of a decoded
+// copy. Errors decoding values are kept, to be returned once a diff is done
+type jsonSource struct {
+	buf []byte
+
+	lk  sync.Mutex
+	err error
+}
+
+// setErr keeps the first error decoding a value
+func (s *jsonSource) setErr(err error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Err returns any error decoding a value
+func (s *jsonSource) Err() error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.err
+}
+
+// jsonValue is a value encoded in a range of a JSON source, decoded the first
+// time it's read
+type jsonValue struct {
+	src        *jsonSource
+	start, end int64
+
+	once sync.Once
+	val  interface{}
+}
+
+// decode materializes the value. values that fail to decode are nil, & the
+// error is kept by the source
+func (v *jsonValue) decode() interface{} {
+	v.once.Do(func() {
+		if err := json.Unmarshal(v.src.buf[v.start:v.end], &v.val); err != nil {
+			v.src.setErr(fmt.Errorf("decoding JSON value: %s", err))
+			v.val = nil
+		}
+	})
+	return v.val
+}
+
+// materialize decodes v if it's a lazily-decoded value
+func materialize(v interface{}) interface{} {
+	if raw, ok := v.(*jsonValue); ok {
+		return raw.decode()
+	}
+	return v
+}
+
+// DiffJSON computes deltas between two JSON documents, building diff trees
+// directly from their JSON tokens. Both documents are read into memory in
+// full, but objects & arrays are kept as ranges of the raw bytes and only
+// decoded when they're needed to describe a delta, which skips the decoded
+// copy of each document that Diff needs. Deltas match those of Diff on the
+// unmarshaled documents: when an object repeats a key, the last value wins.
+// Transforms need decoded values, when any are configured both documents are
+// unmarshaled before diffing
+func (dd *DeepDiff) DiffJSON(ctx context.Context, r1, r2 io.Reader) (Deltas, error) {
+	if len(dd.transforms) > 0 {
+		var a, b interface{}
+		if err := json.NewDecoder(r1).Decode(&a); err != nil {
+			return nil, err
+		}
+		if err := json.NewDecoder(r2).Decode(&b); err != nil {
+			return nil, err
+		}
+		return dd.Diff(ctx, a, b)
+	}
+
+	var (
+		t1, t2     *builtTree
+		err1, err2 error
+		done       = make(chan struct{})
+	)
+	go func() {
+		t1, err1 = (&diff{DeepDiff: dd}).buildJSONTree(r1)
+		close(done)
+	}()
+	t2, err2 = (&diff{DeepDiff: dd}).buildJSONTree(r2)
+	<-done
+	if err1 != nil {
+		return nil, err1
+	}
+	if err2 != nil {
+		return nil, err2
+	}
+
+	deepdiff := dd.prepareTree(t1.root, t1.nodes).newDiff(nil)
+	deepdiff.right = t2
+	deltas := deepdiff.diff(ctx)
+	if err := t1.json.Err(); err != nil {
+		return nil, err
+	}
+	if err := t2.json.Err(); err != nil {
+		return nil, err
+	}
+	return deltas, nil
+}
+
+// buildJSONTree reads a JSON document & builds a tree from it
+func (d *diff) buildJSONTree(r io.Reader) (*builtTree, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	src := &jsonSource{buf: buf}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	a := newArena(d.newHash)
+	root, err := d.jsonTree(src, dec, RootAddr{}, nil, a)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid JSON: unexpected data after top-level value")
+	}
+	return &builtTree{root: root, nodes: a.nodes, json: src}, nil
+}
+
+// jsonTree builds a node from the next value in a stream of JSON tokens in
+// arena a, which lists the node & all its descendants in bottom-up order
+func (d *diff) jsonTree(src *jsonSource, dec *json.Decoder, addr Addr, parent node, a *arena) (node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('['):
+		start := dec.InputOffset() - 1
+		arr := a.array()
+		*arr = array{
+			addr:   addr,
+			parent: parent,
+			mode:   d.arrayMode(parent, addr),
+		}
+		for i := 0; dec.More(); i++ {
+			mark := len(a.nodes)
+			ch, err := d.jsonTree(src, dec, IndexAddr(i), arr, a)
+			if err != nil {
+				return nil, err
+			}
+			// arrays can't have missing elements, values treated as missing are
+			// compared as null
+			if d.absentNode(ch) {
+				a.nodes = a.nodes[:mark]
+				ch = d.scalar(nil, IndexAddr(i), arr, a)
+				a.add(ch)
+			}
+			arr.children = append(arr.children, ch)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		arr.value = a.jsonValue(src, start, dec.InputOffset())
+		d.sealArray(arr, a)
+		a.add(arr)
+		return arr, nil
+
+	case json.Delim('{'):
+		start := dec.InputOffset() - 1
+		mark := len(a.nodes)
+		obj := a.object()
+		*obj = object{
+			addr:   addr,
+			parent: parent,
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := tok.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid JSON: expected object key, got %v", tok)
+			}
+			ch, err := d.jsonTree(src, dec, StringAddr(key), obj, a)
+			if err != nil {
+				return nil, err
+			}
+			obj.children = append(obj.children, ch)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		obj.value = a.jsonValue(src, start, dec.InputOffset())
+
+		sort.SliceStable(obj.children, func(i, j int) bool {
+			return obj.children[i].Addr().String() < obj.children[j].Addr().String()
+		})
+		d.dropHiddenKeys(obj, a, mark)
+		d.sealObject(obj, a)
+		a.add(obj)
+		return obj, nil
+
+	default:
+		n := d.scalar(tok, addr, parent, a)
+		a.add(n)
+		return n, nil
+	}
+}
+
+// dropHiddenKeys removes children of an object built from JSON that aren't
+// part of the decoded object. Like encoding/json, the last value of a repeated
+// key wins. Keys with values treated as missing are skipped entirely. Nodes
+// built for removed children are dropped from arena a, from mark on. Children
+// must be stably sorted by key
+func (d *diff) dropHiddenKeys(obj *object, a *arena, mark int) {
+	var (
+		kept = obj.children[:0]
+		drop map[node]bool
+	)
+	for i, ch := range obj.children {
+		last := i+1 == len(obj.children) || obj.children[i+1].Addr().String() != ch.Addr().String()
+		if last && !d.absentNode(ch) {
+			kept = append(kept, ch)
+			continue
+		}
+		if drop == nil {
+			drop = map[node]bool{}
+		}
+		walk(ch, nil, func(_ []Addr, n node) bool {
+			drop[n] = true
+			return true
+		})
+	}
+	if drop == nil {
+		return
+	}
+
+	obj.children = kept
+	built := a.nodes[:mark]
+	for _, n := range a.nodes[mark:] {
+		if !drop[n] {
+			built = append(built, n)
+		}
+	}
+	a.nodes = built
+}
+
+// absentNode returns true if n is configured to be treated as a missing value.
+// children of n must already have had missing values removed
+func (d *diff) absentNode(n node) bool {
+	switch n.Type() {
+	case ntNull:
+		return d.nullAsMissing
+	case ntArray:
+		return d.emptyArrayAsMissing && len(n.(*array).children) == 0
+	case ntObject:
+		return d.emptyObjectAsMissing && len(n.(*object).children) == 0
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package deepdiff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDiffJSON(t *testing.T) {
+	pairs := [][2]string{
+		{`{"a":1,"b":[1,2,3]}`, `{"a":2,"b":[1,3]}`},
+		{`[[0,1,2]]`, `[[0,1,3]]`},
+		{`{"a":{"b":{"c":[true,"d",null]}},"e":"f"}`, `{"a":{"b":{"c":[false,"d",null]}},"g":{"h":[]}}`},
+		{`"a"`, `{"a":"b"}`},
+		{`{"a":null,"b":[],"c":{"d":null}}`, `{"b":[null,{}],"e":{}}`},
+		{`{"tags":["x","y","z"],"at":"2019-02-22T14:21:27Z"}`, `{"tags":["z","x"],"at":"2019-02-22T09:21:27-05:00"}`},
+		// repeated keys, the last value wins
+		{`{"a":1,"a":2,"b":true}`, `{"a":2,"b":true}`},
+		{`{"a":{"x":[1,2]},"b":1,"a":[3]}`, `{"a":[3,4],"b":1}`},
+		{`{"a":1,"a":null,"b":{}}`, `{"a":1,"b":{"c":1,"c":{}}}`},
+		{`[{"k":1,"k":{"z":1}},{"k":2}]`, `[{"k":{"z":2}}]`},
+	}
+
+	configs := map[string]DiffOption{
+		"default": func(c *Config) {},
+		"changes": func(c *Config) { c.CalcChanges = true },
+		"missing": func(c *Config) {
+			c.NullAsMissing = true
+			c.EmptyArrayAsMissing = true
+			c.EmptyObjectAsMissing = true
+		},
+		"patterns": func(c *Config) {
+			c.ArrayModes = map[string]ArrayMode{"/tags": ArraySet}
+			c.Comparators = map[string]Comparator{"/at": TimeComparator()}
+		},
+	}
+
+	ctx := context.Background()
+	for name, opt := range configs {
+		dd := New(opt)
+		for i, p := range pairs {
+			var a, b interface{}
+			if err := json.Unmarshal([]byte(p[0]), &a); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(p[1]), &b); err != nil {
+				t.Fatal(err)
+			}
+			expect, err := dd.Diff(ctx, a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := dd.DiffJSON(ctx, strings.NewReader(p[0]), strings.NewReader(p[1]))
+			if err != nil {
+				t.Fatalf("%s %d: %s", name, i, err)
+			}
+
+			expectJSON, _ := json.Marshal(expect)
+			gotJSON, _ := json.Marshal(got)
+			if string(expectJSON) != string(gotJSON) {
+				t.Errorf("%s %d: result mismatch.\nwant: %s\ngot:  %s", name, i, expectJSON, gotJSON)
+			}
+		}
+	}
+}
+
+func TestDiffJSONErrors(t *testing.T) {
+	cases := []struct {
+		a, b string
+	}{
+		{`{"a":`, `{}`},
+		{`{}`, `[1,2`},
+		{`{} {}`, `{}`},
+		{``, `{}`},
+	}
+
+	dd := New()
+	for i, c := range cases {
+		if _, err := dd.DiffJSON(context.Background(), strings.NewReader(c.a), strings.NewReader(c.b)); err == nil {
+			t.Errorf("case %d: expected error, got nil", i)
+		}
+	}
+}
+
+func TestJSONValue(t *testing.T) {
+	src := &jsonSource{buf: []byte(`{"a":[1,2]} {"a":`)}
+	v := &jsonValue{src: src, start: 0, end: 11}
+	a := v.decode().(map[string]interface{})
+	a["b"] = true
+	if _, ok := v.decode().(map[string]interface{})["b"]; !ok {
+		t.Error("expected decoded value to be kept")
+	}
+	if err := src.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := &jsonValue{src: src, start: 12, end: int64(len(src.buf))}
+	if got := bad.decode(); got != nil {
+		t.Errorf("expected nil decoding invalid JSON, got: %v", got)
+	}
+	if src.Err() == nil {
+		t.Error("expected source to keep the decoding error")
+	}
+}
+
+func BenchmarkDiffJSON(b *testing.B) {
+	var (
+		dd    = New()
+		ctx   = context.Background()
+		d1, _ = json.Marshal(makeRows(20000, 0))
+		d2, _ = json.Marshal(makeRows(20000, 50))
+	)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dd.DiffJSON(ctx, bytes.NewReader(d1), bytes.NewReader(d2))
+	}
+}
@@ -0,0 +1,137 @@
+package deepdiff
+
+// seqEdit is a single step in an edit script between two sequences a & b.
+// DTContext steps keep a[aIdx] as b[bIdx], DTDelete steps drop a[aIdx], and
+// DTInsert steps add b[bIdx]
+type seqEdit struct {
+	op   Operation
+	aIdx int
+	bIdx int
+}
+
+// myers calculates a minimal edit script between a sequence of length n and a
+// sequence of length m using the greedy algorithm outlined in:
+// An O(ND) Difference Algorithm and Its Variations by Eugene W. Myers
+// http://www.xmailserver.org/diff2.pdf
+// elements are compared by index with eq. Common prefixes & suffixes are
+// trimmed before searching, space used is O(D^2) for D differences
+func myers(n, m int, eq func(i, j int) bool) []seqEdit {
+	edits, _ := boundedMyers(n, m, -1, eq)
+	return edits
+}
+
+// boundedMyers is myers that gives up once more than maxD differences are
+// found, returning false. A negative maxD never gives up
+func boundedMyers(n, m, maxD int, eq func(i, j int) bool) ([]seqEdit, bool) {
+	pre := 0
+	for pre < n && pre < m && eq(pre, pre) {
+		pre++
+	}
+	suf := 0
+	for suf < n-pre && suf < m-pre && eq(n-1-suf, m-1-suf) {
+		suf++
+	}
+
+	middle, ok := myersMiddle(pre, n-pre-suf, m-pre-suf, maxD, eq)
+	if !ok {
+		return nil, false
+	}
+
+	edits := make([]seqEdit, 0, n+m-pre-suf)
+	for i := 0; i < pre; i++ {
+		edits = append(edits, seqEdit{op: DTContext, aIdx: i, bIdx: i})
+	}
+	edits = append(edits, middle...)
+	for i := suf; i > 0; i-- {
+		edits = append(edits, seqEdit{op: DTContext, aIdx: n - i, bIdx: m - i})
+	}
+	return edits, true
+}
+
+// myersMiddle runs the greedy search on the sequences a[off:off+n] and
+// b[off:off+m], giving up after maxD differences unless maxD is negative
+func myersMiddle(off, n, m, maxD int, eq func(i, j int) bool) ([]seqEdit, bool) {
+	max := n + m
+	if max == 0 {
+		return nil, true
+	}
+	if maxD < 0 || maxD > max {
+		maxD = max
+	}
+
+	// v holds the furthest reaching x value for each diagonal k, offset by
+	// max+1 so k-1 & k+1 are always in range. trace keeps a snapshot of
+	// diagonals -d..d after each step d for backtracking
+	vOff := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+	found := false
+
+SEARCH:
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[vOff+k-1] < v[vOff+k+1]) {
+				x = v[vOff+k+1]
+			} else {
+				x = v[vOff+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && eq(off+x, off+y) {
+				x++
+				y++
+			}
+			v[vOff+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[vOff-d:vOff+d+1]...))
+				found = true
+				break SEARCH
+			}
+		}
+		trace = append(trace, append([]int(nil), v[vOff-d:vOff+d+1]...))
+	}
+	if !found {
+		return nil, false
+	}
+
+	// backtrack from the bottom-right corner, building edits in reverse
+	edits := make([]seqEdit, 0, max)
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, seqEdit{op: DTContext, aIdx: off + x, bIdx: off + y})
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, seqEdit{op: DTInsert, aIdx: off + x, bIdx: off + y})
+		} else {
+			x--
+			edits = append(edits, seqEdit{op: DTDelete, aIdx: off + x, bIdx: off + y})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, seqEdit{op: DTContext, aIdx: off + x, bIdx: off + y})
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits, true
+}
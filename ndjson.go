@@ -0,0 +1,50 @@
+package deepdiff
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DecodeNDJSON reads a newline-delimited JSON document, where each line holds
+// one JSON value, as an array of values. Blank lines are skipped
+func DecodeNDJSON(r io.Reader) ([]interface{}, error) {
+	var (
+		rdr  = bufio.NewReader(r)
+		vals = []interface{}{}
+	)
+	for n := 1; ; n++ {
+		line, err := rdr.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var v interface{}
+			if jerr := json.Unmarshal(trimmed, &v); jerr != nil {
+				return nil, fmt.Errorf("line %d: %s", n, jerr)
+			}
+			vals = append(vals, v)
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return vals, nil
+}
+
+// DiffNDJSON computes deltas between two newline-delimited JSON documents,
+// diffing them as arrays of values
+func (dd *DeepDiff) DiffNDJSON(ctx context.Context, r1, r2 io.Reader) (Deltas, error) {
+	a, err := DecodeNDJSON(r1)
+	if err != nil {
+		return nil, err
+	}
+	b, err := DecodeNDJSON(r2)
+	if err != nil {
+		return nil, err
+	}
+	return dd.Diff(ctx, a, b)
+}
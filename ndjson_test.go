@@ -0,0 +1,50 @@
+package deepdiff
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecodeNDJSON(t *testing.T) {
+	got, err := DecodeNDJSON(strings.NewReader("{\"a\":1}\n\n[true,null]\r\n\"b\""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []interface{}{
+		map[string]interface{}{"a": float64(1)},
+		[]interface{}{true, nil},
+		"b",
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := DecodeNDJSON(strings.NewReader("{\"a\":1}\n{\"a\":\n")); err == nil {
+		t.Error("expected error decoding invalid line")
+	} else if !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("expected error to report line 2, got: %s", err)
+	}
+}
+
+func TestDiffNDJSON(t *testing.T) {
+	a := "{\"id\":1}\n{\"id\":2}\n"
+	b := "{\"id\":1}\n{\"id\":3}\n"
+
+	got, err := New().DiffNDJSON(context.Background(), strings.NewReader(a), strings.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Deltas{
+		{Type: DTContext, Path: IndexAddr(0), Value: map[string]interface{}{"id": float64(1)}},
+		{Type: DTContext, Path: IndexAddr(1), Deltas: Deltas{
+			{Type: DTDelete, Path: StringAddr("id"), Value: float64(2)},
+			{Type: DTInsert, Path: StringAddr("id"), Value: float64(3)},
+		}},
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -30,6 +30,13 @@ func patch(target reflect.Value, delta *Delta) (reflect.Value, error) {
 		target = target.Elem()
 	}
 
+	// renamed values move before any changes within them apply
+	if delta.Type == DTRename {
+		if target, err = rename(target, delta.SourcePath, delta.Path); err != nil {
+			return target, err
+		}
+	}
+
 	// traverse the tree bottom-up, setting each parent
 	// value to the updated child
 	if len(delta.Deltas) > 0 {
@@ -85,6 +92,25 @@ func set(target, value reflect.Value, addr Addr) (reflect.Value, error) {
 	return target, nil
 }
 
+// rename moves the value of key src in an object to addr
+func rename(target reflect.Value, src string, addr Addr) (reflect.Value, error) {
+	if target.Kind() == reflect.Interface || target.Kind() == reflect.Ptr {
+		target = target.Elem()
+	}
+	if target.Kind() != reflect.Map {
+		return target, fmt.Errorf("can't rename %q: only object keys can be renamed", src)
+	}
+
+	key := reflect.ValueOf(src)
+	value := target.MapIndex(key)
+	if !value.IsValid() {
+		return target, fmt.Errorf("can't rename %q: key doesn't exist", src)
+	}
+	target.SetMapIndex(key, reflect.Value{})
+	target.SetMapIndex(reflect.ValueOf(addr.Value()), value)
+	return target, nil
+}
+
 func remove(target reflect.Value, addr Addr) (reflect.Value, error) {
 	if target.Kind() == reflect.Interface || target.Kind() == reflect.Ptr {
 		target = target.Elem()
@@ -126,6 +152,12 @@ func insert(target, value reflect.Value, addr Addr) (reflect.Value, error) {
 		return value, nil
 	}
 
+	// null values have no reflect value, insert the zero value of the
+	// container's element type instead
+	if !value.IsValid() && (target.Kind() == reflect.Map || target.Kind() == reflect.Slice) {
+		value = reflect.Zero(target.Type().Elem())
+	}
+
 	switch target.Kind() {
 	case reflect.Map:
 		target.SetMapIndex(reflect.ValueOf(addr.Value()), value)
@@ -61,6 +61,18 @@ func TestPatch(t *testing.T) {
 			map[string]interface{}{"a": false},
 			Deltas{{Type: DTInsert, Path: StringAddr("a"), Value: false}},
 		},
+		{
+			"insert null into object",
+			map[string]interface{}{},
+			map[string]interface{}{"a": nil},
+			Deltas{{Type: DTInsert, Path: StringAddr("a"), Value: nil}},
+		},
+		{
+			"insert null into array",
+			[]interface{}{float64(1)},
+			[]interface{}{nil, float64(1)},
+			Deltas{{Type: DTInsert, Path: IndexAddr(0), Value: nil}},
+		},
 		{
 			"delete from end of array",
 			[]interface{}{"a", "b", "c"},
@@ -132,6 +144,16 @@ func TestPatch(t *testing.T) {
 				}},
 			},
 		},
+		{
+			"rename key & change renamed value",
+			map[string]interface{}{"a": map[string]interface{}{"b": true}, "c": true},
+			map[string]interface{}{"d": map[string]interface{}{"b": false}, "c": true},
+			Deltas{
+				{Type: DTRename, Path: StringAddr("d"), SourcePath: "a", Deltas: Deltas{
+					{Type: DTUpdate, Path: StringAddr("b"), Value: false},
+				}},
+			},
+		},
 	}
 
 	for _, c := range cases {
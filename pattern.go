@@ -0,0 +1,109 @@
+package deepdiff
+
+import (
+	pathpkg "path"
+	"sort"
+	"strings"
+)
+
+// pathPattern matches node paths within a document. Patterns are written as
+// slash-delimited address strings, where "*" matches any single address and
+// "**" matches zero or more addresses. Other segments are matched against
+// addresses with path.Match, so "*At" matches any address ending in "At".
+// "/" matches the root of a document.
+// eg: "/tags", "/users/*/roles", "/**/createdAt"
+type pathPattern struct {
+	raw      string
+	segments []string
+}
+
+func parsePathPattern(s string) pathPattern {
+	p := pathPattern{raw: s}
+	for _, seg := range strings.Split(s, "/") {
+		if seg != "" {
+			p.segments = append(p.segments, seg)
+		}
+	}
+	return p
+}
+
+// match reports whether path satisfies the pattern
+func (p pathPattern) match(path []Addr) bool {
+	return matchSegments(p.segments, path)
+}
+
+func matchSegments(segments []string, path []Addr) bool {
+	for len(segments) > 0 {
+		switch segments[0] {
+		case "**":
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(segments[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+			if len(path) == 0 {
+				return false
+			}
+		default:
+			if len(path) == 0 {
+				return false
+			}
+			if ok, _ := pathpkg.Match(segments[0], path[0].String()); !ok {
+				return false
+			}
+		}
+		segments = segments[1:]
+		path = path[1:]
+	}
+	return len(path) == 0
+}
+
+// literals counts the segments in a pattern that don't contain wildcards
+func (p pathPattern) literals() (n int) {
+	for _, seg := range p.segments {
+		if !strings.ContainsAny(seg, `*?[\`) {
+			n++
+		}
+	}
+	return n
+}
+
+// pathPatterns is a set of patterns ordered from most to least specific
+type pathPatterns []pathPattern
+
+// newPathPatterns parses a list of patterns
+func newPathPatterns(raw []string) pathPatterns {
+	ps := make(pathPatterns, len(raw))
+	for i, s := range raw {
+		ps[i] = parsePathPattern(s)
+	}
+	sort.Sort(ps)
+	return ps
+}
+
+func (ps pathPatterns) Len() int      { return len(ps) }
+func (ps pathPatterns) Swap(i, j int) { ps[i], ps[j] = ps[j], ps[i] }
+
+// Less sorts patterns with more literal segments first, then longer patterns,
+// falling back to the pattern string for a total order
+func (ps pathPatterns) Less(i, j int) bool {
+	if li, lj := ps[i].literals(), ps[j].literals(); li != lj {
+		return li > lj
+	}
+	if len(ps[i].segments) != len(ps[j].segments) {
+		return len(ps[i].segments) > len(ps[j].segments)
+	}
+	return ps[i].raw < ps[j].raw
+}
+
+// match returns the most specific pattern that matches path
+func (ps pathPatterns) match(path []Addr) (pattern string, ok bool) {
+	for _, p := range ps {
+		if p.match(path) {
+			return p.raw, true
+		}
+	}
+	return "", false
+}
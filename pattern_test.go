@@ -0,0 +1,54 @@
+package deepdiff
+
+import "testing"
+
+func TestPathPatternMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    []Addr
+		expect  bool
+	}{
+		{"/", nil, true},
+		{"/", []Addr{StringAddr("a")}, false},
+		{"/tags", []Addr{StringAddr("tags")}, true},
+		{"/tags", []Addr{StringAddr("tags"), IndexAddr(0)}, false},
+		{"/users/*/roles", []Addr{StringAddr("users"), IndexAddr(3), StringAddr("roles")}, true},
+		{"/users/*/roles", []Addr{StringAddr("users"), StringAddr("roles")}, false},
+		{"/users/1", []Addr{StringAddr("users"), IndexAddr(1)}, true},
+		{"/**", nil, true},
+		{"/**", []Addr{StringAddr("a"), IndexAddr(1)}, true},
+		{"/**/createdAt", []Addr{StringAddr("createdAt")}, true},
+		{"/**/createdAt", []Addr{StringAddr("a"), IndexAddr(0), StringAddr("createdAt")}, true},
+		{"/**/createdAt", []Addr{StringAddr("createdAt"), StringAddr("b")}, false},
+		{"/**/*At", []Addr{StringAddr("a"), StringAddr("updatedAt")}, true},
+		{"/**/*At", []Addr{StringAddr("a"), StringAddr("updated")}, false},
+		{"/rows/1?", []Addr{StringAddr("rows"), IndexAddr(12)}, true},
+	}
+
+	for i, c := range cases {
+		if got := parsePathPattern(c.pattern).match(c.path); got != c.expect {
+			t.Errorf("%d %s %v: expected %t, got %t", i, c.pattern, c.path, c.expect, got)
+		}
+	}
+}
+
+func TestPathPatternsSpecificity(t *testing.T) {
+	ps := newPathPatterns([]string{"/**", "/users/*/roles", "/**/roles", "/users/0/roles", "/users/*/r*"})
+	cases := []struct {
+		path   []Addr
+		expect string
+	}{
+		{[]Addr{StringAddr("users"), IndexAddr(0), StringAddr("roles")}, "/users/0/roles"},
+		{[]Addr{StringAddr("users"), IndexAddr(1), StringAddr("roles")}, "/users/*/roles"},
+		{[]Addr{StringAddr("groups"), StringAddr("roles")}, "/**/roles"},
+		{[]Addr{StringAddr("groups")}, "/**"},
+		{[]Addr{StringAddr("users"), IndexAddr(1), StringAddr("rank")}, "/users/*/r*"},
+	}
+
+	for i, c := range cases {
+		got, ok := ps.match(c.path)
+		if !ok || got != c.expect {
+			t.Errorf("%d: expected %q, got %q", i, c.expect, got)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package deepdiff
+
+import (
+	"context"
+	"sort"
+)
+
+// Prepared is a document tree built once & diffed against any number of other
+// documents, skipping the cost of rebuilding & rehashing the left side of each
+// diff. A Prepared document is never modified by diffing, so it's safe to
+// diff concurrently
+type Prepared struct {
+	dd     *DeepDiff
+	tree   node
+	nodes  map[string][]node
+	count  int
+	weight int
+}
+
+// Prepare builds a reusable tree from a, to be used as the left side of diffs.
+// Diffs of a Prepared document use the configuration of the DeepDiff that
+// prepared it
+// currently Prepare will never return an error, error returns are reserved for
+// future use
+func (dd *DeepDiff) Prepare(ctx context.Context, a interface{}) (*Prepared, error) {
+	return dd.prepare(a), nil
+}
+
+func (dd *DeepDiff) prepare(a interface{}) *Prepared {
+	return dd.prepareTree((&diff{DeepDiff: dd}).buildTree(a))
+}
+
+// prepareTree indexes an already-built tree
+func (dd *DeepDiff) prepareTree(root node, all []node) *Prepared {
+	p := &Prepared{dd: dd, tree: root, nodes: map[string][]node{}}
+	for i, n := range all {
+		n.setIndex(i)
+		key := hashStr(n.Hash())
+		p.nodes[key] = append(p.nodes[key], n)
+		p.weight += n.Weight()
+	}
+	p.count = len(all)
+	return p
+}
+
+// Diff computes the deltas that turn the prepared document into b
+func (p *Prepared) Diff(ctx context.Context, b interface{}) (Deltas, error) {
+	deepdiff := p.newDiff(b)
+	return deepdiff.diff(ctx), nil
+}
+
+// StatDiff calculates a diff script and diff stats from the prepared document
+// to b
+func (p *Prepared) StatDiff(ctx context.Context, b interface{}) (Deltas, *Stats, error) {
+	deepdiff := p.newDiff(b)
+	deepdiff.stats = &Stats{}
+	return deepdiff.diff(ctx), deepdiff.stats, nil
+}
+
+// Stat calculates the DiffStats between the prepared document & b
+func (p *Prepared) Stat(ctx context.Context, b interface{}) (*Stats, error) {
+	deepdiff := p.newDiff(b)
+	deepdiff.stats = &Stats{}
+	deepdiff.diff(ctx)
+	return deepdiff.stats, nil
+}
+
+func (p *Prepared) newDiff(b interface{}) *diff {
+	return &diff{DeepDiff: p.dd, prepared: p, d2: b}
+}
+
+// initState allocates diff state for a count of nodes across both trees
+func (d *diff) initState(count int) {
+	d.matches = make([]node, count)
+	d.changeTypes = make([]Operation, count)
+	d.addrs = make([]Addr, count)
+}
+
+// match gets the counterpart of n in the other tree, if any
+func (d *diff) match(n node) node { return d.matches[n.index()] }
+
+// setMatch assigns the counterpart of n
+func (d *diff) setMatch(n, m node) { d.matches[n.index()] = m }
+
+// changeType gets the modification type of n
+func (d *diff) changeType(n node) Operation { return d.changeTypes[n.index()] }
+
+// setChangeType assigns the modification type of n
+func (d *diff) setChangeType(n node, o Operation) { d.changeTypes[n.index()] = o }
+
+// addr gets the address of n, accounting for any shifts caused by inserting
+// & deleting array elements
+func (d *diff) addr(n node) Addr {
+	if a := d.addrs[n.index()]; a != nil {
+		return a
+	}
+	return n.Addr()
+}
+
+// setAddr shifts the address of n
+func (d *diff) setAddr(n node, a Addr) { d.addrs[n.index()] = a }
+
+// walkSorted walks a tree in top-down (prefix) order using shifted
+// addresses, sorting children by address before recursing
+func (d *diff) walkSorted(tree node, path []Addr, fn func(path []Addr, n node) bool) {
+	if addr := d.addr(tree); !addr.Eq(RootAddr{}) {
+		path = append(path, addr)
+	}
+
+	kontinue := fn(path, tree)
+	if cmp, ok := tree.(compound); kontinue && ok {
+		// copy children before sorting, array children are shared with the tree
+		children := make([]node, len(cmp.Children()))
+		copy(children, cmp.Children())
+		sort.SliceStable(children, func(i, j int) bool {
+			return d.addr(children[i]).String() < d.addr(children[j]).String()
+		})
+		for _, n := range children {
+			d.walkSorted(n, path, fn)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package deepdiff
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func TestPrepared(t *testing.T) {
+	base := `{"a":[1,2,3,4],"b":{"c":"d","e":[{"f":true},{"g":false}]},"h":null}`
+	candidates := []string{
+		base,
+		`{"a":[1,3,4],"b":{"c":"d","e":[{"f":true},{"g":false}]},"h":null}`,
+		`{"a":[0,1,2,3,4,5],"b":{"c":"x","e":[{"g":false}]}}`,
+		`{"b":{"c":"d","e":[{"f":true},{"g":false},{"i":1}]},"h":[1,2]}`,
+		`[1,2,3]`,
+		`{"a":[4,3,2,1],"b":{},"h":null,"j":"k"}`,
+	}
+
+	var a interface{}
+	if err := json.Unmarshal([]byte(base), &a); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	dd := New(func(c *Config) { c.CalcChanges = true })
+	p, err := dd.Prepare(ctx, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := make([]string, len(candidates))
+	for i, c := range candidates {
+		var a, b interface{}
+		json.Unmarshal([]byte(base), &a)
+		json.Unmarshal([]byte(c), &b)
+		deltas, err := dd.Diff(ctx, a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(deltas)
+		expect[i] = string(data)
+	}
+
+	wg := sync.WaitGroup{}
+	for repeat := 0; repeat < 4; repeat++ {
+		for i, c := range candidates {
+			wg.Add(1)
+			go func(i int, c string) {
+				defer wg.Done()
+				var b interface{}
+				if err := json.Unmarshal([]byte(c), &b); err != nil {
+					t.Error(err)
+					return
+				}
+				deltas, err := p.Diff(ctx, b)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if data, _ := json.Marshal(deltas); string(data) != expect[i] {
+					t.Errorf("candidate %d mismatch.\nwant: %s\ngot:  %s", i, expect[i], data)
+				}
+			}(i, c)
+		}
+	}
+	wg.Wait()
+
+	stats, err := p.Stat(ctx, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Left != stats.Right || stats.NodeChange() != 0 {
+		t.Errorf("expected no changes diffing a prepared document against itself, got: %#v", stats)
+	}
+}
@@ -0,0 +1,89 @@
+package deepdiff
+
+import "bytes"
+
+// matchRenames walks t2 top-down, pairing keys that only exist in one of two
+// matched objects when they hold identical or similar values. Paired values
+// are matched & marked as renames, their descendants are matched in turn so
+// changes within a renamed value nest under the rename
+func (d *diff) matchRenames(t2 node) {
+	walk(t2, nil, func(_ []Addr, n node) bool {
+		b, ok := n.(*object)
+		if !ok {
+			return true
+		}
+		if a, ok := d.match(n).(*object); ok {
+			d.renameKeys(a, b)
+		}
+		return true
+	})
+}
+
+// renameKeys pairs children of a with keys missing from b to children of b
+// with keys missing from a. Identical values pair first, remaining compound
+// values pair with the most similar candidate of the same type that meets the
+// rename threshold. Ties go to the first key, keeping renames deterministic
+func (d *diff) renameKeys(a, b *object) {
+	var deleted, inserted []node
+	for _, ach := range a.children {
+		if d.match(ach) == nil && b.Child(ach.Addr()) == nil {
+			deleted = append(deleted, ach)
+		}
+	}
+	for _, bch := range b.children {
+		if d.match(bch) == nil && a.Child(bch.Addr()) == nil {
+			inserted = append(inserted, bch)
+		}
+	}
+	if len(deleted) == 0 || len(inserted) == 0 {
+		return
+	}
+
+	for _, bch := range inserted {
+		for _, ach := range deleted {
+			if d.match(ach) == nil && bytes.Equal(ach.Hash(), bch.Hash()) {
+				if d.verifyMatches && len(verifiedCandidates([]node{ach}, bch)) == 0 {
+					continue
+				}
+				d.rename(ach, bch)
+				break
+			}
+		}
+	}
+
+	if d.renameThreshold >= 1 {
+		return
+	}
+	for _, bch := range inserted {
+		if d.match(bch) != nil {
+			continue
+		}
+		var (
+			best  node
+			score float64
+		)
+		for _, ach := range deleted {
+			if d.match(ach) != nil || ach.Type() != bch.Type() {
+				continue
+			}
+			if s := similarity(ach, bch); s >= d.renameThreshold && s > score {
+				best, score = ach, s
+			}
+		}
+		if best != nil {
+			d.rename(best, bch)
+		}
+	}
+}
+
+// rename matches n1 to n2 as a renamed value, matching descendants of the
+// pair by position
+func (d *diff) rename(n1, n2 node) {
+	d.setMatch(n1, n2)
+	d.setMatch(n2, n1)
+	d.setChangeType(n2, DTRename)
+	walk(n2, nil, func(_ []Addr, n node) bool {
+		d.propagateMatchToChildren(n)
+		return true
+	})
+}
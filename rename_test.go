@@ -0,0 +1,125 @@
+package deepdiff
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestRenames(t *testing.T) {
+	renames := func(cfg *Config) { cfg.RenameThreshold = 0.5 }
+
+	cases := []TestCase{
+		{
+			"renamed scalar",
+			`{"name":"a","zip":"12345"}`,
+			`{"name":"a","postalCode":"12345"}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("name"), Value: "a"},
+				{Type: DTRename, Path: StringAddr("postalCode"), SourcePath: "zip", Value: "12345"},
+			},
+		},
+		{
+			"renamed & changed object",
+			`{"addr":{"city":"x","street":"1 main","zip":"1"},"n":1}`,
+			`{"home":{"city":"x","street":"1 main","zip":"2"},"n":1}`,
+			Deltas{
+				{Type: DTRename, Path: StringAddr("home"), SourcePath: "addr", Deltas: Deltas{
+					{Type: DTContext, Path: StringAddr("city"), Value: "x"},
+					{Type: DTContext, Path: StringAddr("street"), Value: "1 main"},
+					{Type: DTDelete, Path: StringAddr("zip"), Value: "1"},
+					{Type: DTInsert, Path: StringAddr("zip"), Value: "2"},
+				}},
+				{Type: DTContext, Path: StringAddr("n"), Value: float64(1)},
+			},
+		},
+		{
+			"nested rename",
+			`{"a":{"x":[1,2]},"b":"q"}`,
+			`{"a":{"y":[1,2]},"b":"r"}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("a"), Deltas: Deltas{
+					{Type: DTRename, Path: StringAddr("y"), SourcePath: "x", Value: []interface{}{float64(1), float64(2)}},
+				}},
+				{Type: DTDelete, Path: StringAddr("b"), Value: "q"},
+				{Type: DTInsert, Path: StringAddr("b"), Value: "r"},
+			},
+		},
+		{
+			"keys in both objects aren't renamed",
+			`{"a":1,"b":2}`,
+			`{"b":1,"c":2}`,
+			Deltas{
+				{Type: DTDelete, Path: StringAddr("a"), Value: float64(1)},
+				{Type: DTDelete, Path: StringAddr("b"), Value: float64(2)},
+				{Type: DTInsert, Path: StringAddr("b"), Value: float64(1)},
+				{Type: DTInsert, Path: StringAddr("c"), Value: float64(2)},
+			},
+		},
+		{
+			"dissimilar values aren't renamed",
+			`{"a":{"x":1,"y":2}}`,
+			`{"b":{"x":3,"y":4}}`,
+			Deltas{
+				{Type: DTDelete, Path: StringAddr("a"), Value: map[string]interface{}{"x": float64(1), "y": float64(2)}},
+				{Type: DTInsert, Path: StringAddr("b"), Value: map[string]interface{}{"x": float64(3), "y": float64(4)}},
+			},
+		},
+	}
+
+	RunTestCases(t, cases, renames)
+
+	t.Run("top-down", func(t *testing.T) {
+		RunTestCases(t, cases[:1], renames, func(cfg *Config) { cfg.TopDown = true })
+	})
+
+	identical := []TestCase{
+		{
+			"similar values aren't renamed",
+			`{"addr":{"city":"x","street":"1 main","zip":"1"}}`,
+			`{"home":{"city":"x","street":"1 main","zip":"2"}}`,
+			Deltas{
+				{Type: DTDelete, Path: StringAddr("addr"), Value: map[string]interface{}{"city": "x", "street": "1 main", "zip": "1"}},
+				{Type: DTInsert, Path: StringAddr("home"), Value: map[string]interface{}{"city": "x", "street": "1 main", "zip": "2"}},
+			},
+		},
+	}
+
+	RunTestCases(t, identical, func(cfg *Config) { cfg.RenameThreshold = 1 })
+}
+
+func TestRenameOutput(t *testing.T) {
+	var a, b interface{}
+	if err := json.Unmarshal([]byte(`{"id":1,"zip":"12345"}`), &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`{"id":1,"postalCode":"12345"}`), &b); err != nil {
+		t.Fatal(err)
+	}
+
+	deltas, st, err := New(func(cfg *Config) { cfg.RenameThreshold = 1 }).StatDiff(context.Background(), a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Renames != 1 || st.Inserts != 0 || st.Deletes != 0 {
+		t.Errorf("expected 1 rename & no inserts or deletes, got: %#v", st)
+	}
+
+	data, err := json.Marshal(deltas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `[[" ","id",1],["^","postalCode","12345",{"sourcePath":"zip"}]]`
+	if string(data) != expect {
+		t.Errorf("json mismatch.\nwant: %s\ngot:  %s", expect, string(data))
+	}
+
+	str, err := FormatPrettyString(deltas, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = " id: 1\n^zip -> postalCode: \"12345\"\n"
+	if str != expect {
+		t.Errorf("pretty mismatch.\nwant: %q\ngot:  %q", expect, str)
+	}
+}
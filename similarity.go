@@ -0,0 +1,121 @@
+package deepdiff
+
+import "bytes"
+
+// fuzzyMatch walks t2 top-down, pairing unmatched compound nodes with an
+// unmatched node of the same type in the same position of t1 when their
+// similarity meets the configured threshold. Matches are propagated to
+// children as they're made, so nested records are considered in turn
+func (d *diff) fuzzyMatch(t2 node) {
+	walk(t2, nil, func(_ []Addr, n node) bool {
+		if _, ok := n.(compound); !ok || d.match(n) != nil {
+			return true
+		}
+		if can := d.similarCandidate(n); can != nil {
+			d.setMatch(can, n)
+			d.setMatch(n, can)
+			d.propagateMatchToChildren(n)
+		}
+		return true
+	})
+}
+
+// similarCandidate finds the most similar unmatched t1 node in the position
+// of n. Objects can only match the value of the same key in the matched
+// parent, and elements of ordered arrays can match elements between the
+// matches of their nearest matched siblings. Unordered arrays only match
+// elements by hash. Ties go to the first candidate, keeping matching deterministic
+func (d *diff) similarCandidate(n node) node {
+	var candidates []node
+	if parent := n.Parent(); parent == nil {
+		candidates = []node{d.t1}
+	} else if pm, ok := d.match(parent).(compound); ok {
+		if pm.Type() == ntObject {
+			if ch := pm.Child(n.Addr()); ch != nil {
+				candidates = []node{ch}
+			}
+		} else if !unordered(pm) {
+			candidates = d.orderedCandidates(n, pm)
+		}
+	}
+
+	var (
+		best  node
+		score float64
+	)
+	for _, can := range candidates {
+		if can.Type() != n.Type() || d.match(can) != nil {
+			continue
+		}
+		if s := similarity(can, n); s >= d.similarityThreshold && s > score {
+			best, score = can, s
+		}
+	}
+	return best
+}
+
+// similarity scores how much of two compound nodes is shared on a scale of
+// 0 to 1. Children are shared if they have equal hashes & the same key in
+// objects, or equal hashes at any position in arrays. The score is the
+// weight of shared children on both sides over the weight of all children
+func similarity(a, b node) float64 {
+	acmp, aok := a.(compound)
+	bcmp, bok := b.(compound)
+	if !aok || !bok {
+		return 0
+	}
+	total := a.Weight() + b.Weight() - 2
+	if total <= 0 {
+		return 0
+	}
+
+	shared := 0
+	if a.Type() == ntObject {
+		for _, ach := range acmp.Children() {
+			if bch := bcmp.Child(ach.Addr()); bch != nil && bytes.Equal(ach.Hash(), bch.Hash()) {
+				shared += ach.Weight() + bch.Weight()
+			}
+		}
+	} else {
+		byHash := map[string]int{}
+		for _, ach := range acmp.Children() {
+			byHash[hashStr(ach.Hash())]++
+		}
+		for _, bch := range bcmp.Children() {
+			key := hashStr(bch.Hash())
+			if byHash[key] > 0 {
+				byHash[key]--
+				shared += 2 * bch.Weight()
+			}
+		}
+	}
+	return float64(shared) / float64(total)
+}
+
+// orderedCandidates lists the elements of the ordered array pm that n can
+// match without crossing the matches of its siblings, which deltas can't
+// describe
+func (d *diff) orderedCandidates(n node, pm compound) []node {
+	siblings := n.Parent().(compound).Children()
+	elements := pm.Children()
+	idx, _ := n.Addr().Value().(int)
+
+	lo, hi := 0, len(elements)
+	for i := idx - 1; i >= 0; i-- {
+		if m := d.match(siblings[i]); m != nil && m.Parent() == pm {
+			lo, _ = m.Addr().Value().(int)
+			lo++
+			break
+		}
+	}
+	for i := idx + 1; i < len(siblings); i++ {
+		if m := d.match(siblings[i]); m != nil && m.Parent() == pm {
+			hi, _ = m.Addr().Value().(int)
+			break
+		}
+	}
+	if lo >= hi {
+		return nil
+	}
+	return elements[lo:hi]
+}
@@ -0,0 +1,122 @@
+package deepdiff
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSimilarityMatching(t *testing.T) {
+	src := `{"title":"example","rows":[
+		{"a":"v0","b":"v1","c":"v2","d":"v3"},
+		{"a":"v1","b":"v2","c":"v3","d":"v0"},
+		{"a":"v2","b":"v3","c":"v0","d":"v1"}
+	]}`
+	dst := `{"title":"example","rows":[
+		{"a":"v2","b":"v3","c":"changed","d":"v1"}
+	]}`
+
+	matched := Deltas{
+		{Type: DTContext, Path: StringAddr("rows"), Deltas: Deltas{
+			{Type: DTDelete, Path: IndexAddr(0), Value: map[string]interface{}{"a": "v0", "b": "v1", "c": "v2", "d": "v3"}},
+			{Type: DTDelete, Path: IndexAddr(0), Value: map[string]interface{}{"a": "v1", "b": "v2", "c": "v3", "d": "v0"}},
+			{Type: DTContext, Path: IndexAddr(0), Deltas: Deltas{
+				{Type: DTContext, Path: StringAddr("a"), Value: "v2"},
+				{Type: DTContext, Path: StringAddr("b"), Value: "v3"},
+				{Type: DTUpdate, Path: StringAddr("c"), SourceValue: "v0", Value: "changed"},
+				{Type: DTContext, Path: StringAddr("d"), Value: "v1"},
+			}},
+		}},
+		{Type: DTContext, Path: StringAddr("title"), Value: "example"},
+	}
+
+	// without a match the changed row is a delete & insert, with no updates
+	cases := []struct {
+		description string
+		threshold   float64
+		expect      Deltas
+	}{
+		{"default alignment", 0, matched},
+		{"threshold met", 0.5, matched},
+		{"threshold not met", 0.8, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			var a, b interface{}
+			if err := json.Unmarshal([]byte(src), &a); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(dst), &b); err != nil {
+				t.Fatal(err)
+			}
+			dd := New(func(cfg *Config) {
+				cfg.CalcChanges = true
+				cfg.SimilarityThreshold = c.threshold
+			})
+			got, st, err := dd.StatDiff(context.Background(), a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.expect == nil {
+				if st.Updates != 0 {
+					t.Errorf("expected changed row to be inserted, got %d updates", st.Updates)
+				}
+				return
+			}
+			if diff := cmp.Diff(c.expect, got); diff != "" {
+				t.Errorf("result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSimilarityPatches(t *testing.T) {
+	cases := []TestCase{
+		{"nested record change",
+			`{"a":[{"id":1,"tags":["x","y"],"meta":{"n":1,"m":2}},{"id":2,"tags":["z"],"meta":{"n":3,"m":4}}]}`,
+			`{"a":[{"id":2,"tags":["z"],"meta":{"n":3,"m":5}}]}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("a"), Deltas: Deltas{
+					{Type: DTDelete, Path: IndexAddr(0), Value: map[string]interface{}{"id": float64(1), "tags": []interface{}{"x", "y"}, "meta": map[string]interface{}{"n": float64(1), "m": float64(2)}}},
+					{Type: DTContext, Path: IndexAddr(0), Deltas: Deltas{
+						{Type: DTContext, Path: StringAddr("id"), Value: float64(2)},
+						{Type: DTContext, Path: StringAddr("meta"), Deltas: Deltas{
+							{Type: DTDelete, Path: StringAddr("m"), Value: float64(4)},
+							{Type: DTInsert, Path: StringAddr("m"), Value: float64(5)},
+							{Type: DTContext, Path: StringAddr("n"), Value: float64(3)},
+						}},
+						{Type: DTContext, Path: StringAddr("tags"), Value: []interface{}{"z"}},
+					}},
+				}},
+			},
+		},
+	}
+
+	RunTestCases(t, cases, func(cfg *Config) { cfg.SimilarityThreshold = 0.5 })
+}
+
+func TestSimilarity(t *testing.T) {
+	d := &diff{DeepDiff: New()}
+	cases := []struct {
+		a, b   interface{}
+		expect float64
+	}{
+		{map[string]interface{}{"a": "x", "b": "y"}, map[string]interface{}{"a": "x", "b": "y"}, 1},
+		{map[string]interface{}{"a": "x", "b": "y"}, map[string]interface{}{"a": "x", "b": "z"}, 0.5},
+		{map[string]interface{}{"a": "x", "b": "y"}, map[string]interface{}{"b": "x", "a": "y"}, 0},
+		{[]interface{}{"x", "y", "z", "w"}, []interface{}{"w", "z", "q", "r"}, 0.5},
+		{[]interface{}{"x", "x"}, []interface{}{"x"}, float64(2) / 3},
+		{[]interface{}{}, []interface{}{}, 0},
+	}
+
+	for i, c := range cases {
+		a, _ := d.buildTree(c.a)
+		b, _ := d.buildTree(c.b)
+		if got := similarity(a, b); got != c.expect {
+			t.Errorf("case %d: expected %f, got %f", i, c.expect, got)
+		}
+	}
+}
@@ -11,6 +11,10 @@ type Stats struct {
 	Inserts int `json:"inserts,omitempty"` // number of nodes inserted
 	Updates int `json:"updates,omitempty"` // number of nodes updated
 	Deletes int `json:"deletes,omitempty"` // number of nodes deleted
+	Renames int `json:"renames,omitempty"` // number of object keys renamed
+
+	// net number of matches added by each optimize pass, in order
+	OptimizePasses []int `json:"optimizePasses,omitempty"`
 }
 
 // NodeChange returns a count of the shift between left & right trees
@@ -28,6 +28,8 @@ func TestCalcStats(t *testing.T) {
 		Inserts:     4,
 		Updates:     0,
 		Deletes:     4,
+
+		OptimizePasses: []int{18, 0},
 	}
 
 	got, err := New().Stat(context.Background(), a, b)
@@ -0,0 +1,608 @@
+package deepdiff
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TableDiff describes changes between two tables: arrays where every element
+// is a row array of cells. Inserted, deleted, renamed & moved columns are
+// described once for the whole table, and rows describe changes to cells in
+// columns found in both tables
+type TableDiff struct {
+	// Columns lists every column in the order of the B table, followed by
+	// columns deleted from the A table
+	Columns []*ColumnDelta `json:"columns"`
+	// Rows lists inserted, deleted, updated & moved rows in the order of the B
+	// table. Deleted rows are listed before the row that followed them in A
+	Rows []*RowDelta `json:"rows,omitempty"`
+
+	deltas Deltas
+}
+
+// Deltas converts a table diff to an edit script. Cells of inserted & deleted
+// columns are inserted & deleted in every row, & moved rows & columns are
+// deleted & inserted at their new position
+func (td *TableDiff) Deltas() Deltas {
+	return td.deltas
+}
+
+// ColumnDelta describes a column of a table diff
+type ColumnDelta struct {
+	// Type is one of DTContext, DTInsert, DTDelete, or DTRename
+	Type Operation `json:"type"`
+	// Name of the column in B, or A for deleted columns. Tables without a
+	// header name columns by index
+	Name string `json:"name"`
+	// SourceName is the name of a renamed column in A
+	SourceName string `json:"sourceName,omitempty"`
+	// Index is the position of the column in B, -1 for deleted columns
+	Index int `json:"index"`
+	// SourceIndex is the position of the column in A, -1 for inserted columns
+	SourceIndex int `json:"sourceIndex"`
+	// Moved is true if the column changed position relative to other columns
+	Moved bool `json:"moved,omitempty"`
+}
+
+// RowDelta describes a changed row of a table diff
+type RowDelta struct {
+	// Type is DTInsert, DTDelete, DTUpdate for rows with changed cells, or
+	// DTContext for rows that only moved
+	Type Operation `json:"type"`
+	// Index is the position of the row in B, -1 for deleted rows. Any header
+	// row is at index 0
+	Index int `json:"index"`
+	// SourceIndex is the position of the row in A, -1 for inserted rows
+	SourceIndex int `json:"sourceIndex"`
+	// Key is the value of the key column for tables diffed by key
+	Key interface{} `json:"key,omitempty"`
+	// Value is the inserted or deleted row
+	Value interface{} `json:"value,omitempty"`
+	// Cells lists changed cells of updated rows
+	Cells []*CellDelta `json:"cells,omitempty"`
+	// Moved is true if a row matched by key changed position relative to
+	// other rows
+	Moved bool `json:"moved,omitempty"`
+}
+
+// CellDelta describes a changed cell in a row present in both tables
+type CellDelta struct {
+	// Column is the name of the cell's column in B
+	Column string `json:"column"`
+	// Index is the position of the cell's column in B
+	Index int `json:"index"`
+	// Value of the cell in B, nil if the row is too short to have the cell
+	Value interface{} `json:"value"`
+	// SourceValue of the cell in A, nil if the row is too short to have the
+	// cell
+	SourceValue interface{} `json:"sourceValue"`
+}
+
+// DiffTable diffs two tables column by column, returning an error if a or b
+// isn't a table. Tables are read with the TableHeader & TableKey settings
+func (dd *DeepDiff) DiffTable(ctx context.Context, a, b interface{}) (*TableDiff, error) {
+	d := dd.newDiff(a, b)
+	d.t1, d.t2, d.t1Nodes = d.prepTrees(ctx)
+	t1, ok := table(d.t1)
+	if !ok {
+		return nil, fmt.Errorf("a is not a table: expected an array of arrays")
+	}
+	t2, ok := table(d.t2)
+	if !ok {
+		return nil, fmt.Errorf("b is not a table: expected an array of arrays")
+	}
+
+	td, err := d.tableMatch(t1, t2)
+	if err != nil {
+		return nil, err
+	}
+	td.deltas = d.calcDeltas(d.t1, d.t2)
+	return td, nil
+}
+
+// table returns n as an array if it's an ordered array of arrays
+func table(n node) (*array, bool) {
+	arr, ok := n.(*array)
+	if !ok || unordered(arr) {
+		return nil, false
+	}
+	for _, row := range arr.children {
+		if row.Type() != ntArray {
+			return nil, false
+		}
+	}
+	return arr, true
+}
+
+// tableSide is one of the two tables in a table diff
+type tableSide struct {
+	rows  []node   // rows of the table, including any header
+	names []string // column names
+	start int      // index of the first row of data
+}
+
+func (d *diff) tableSide(t *array) *tableSide {
+	s := &tableSide{rows: t.children}
+	width := 0
+	for _, row := range s.rows {
+		if l := len(row.(compound).Children()); l > width {
+			width = l
+		}
+	}
+	s.names = make([]string, width)
+	for i := range s.names {
+		s.names[i] = strconv.Itoa(i)
+	}
+	if d.tableHeader && len(s.rows) > 0 {
+		s.start = 1
+		for i, cell := range s.rows[0].(compound).Children() {
+			if str, ok := cell.Value().(string); ok {
+				s.names[i] = str
+			} else {
+				s.names[i] = fmt.Sprint(cell.Value())
+			}
+		}
+	}
+	return s
+}
+
+// cell returns the cell of a row in column col, nil if the row is too short
+// to have one
+func (s *tableSide) cell(row, col int) node {
+	if cells := s.rows[row].(compound).Children(); col < len(cells) {
+		return cells[col]
+	}
+	return nil
+}
+
+// cellValue returns the value of a cell, nil if there is no cell
+func (s *tableSide) cellValue(row, col int) interface{} {
+	if cell := s.cell(row, col); cell != nil {
+		return cell.Value()
+	}
+	return nil
+}
+
+// column counts the cell hashes of a column's data rows
+func (s *tableSide) column(col int) (counts map[string]int, n int) {
+	counts = map[string]int{}
+	for row := s.start; row < len(s.rows); row++ {
+		if cell := s.cell(row, col); cell != nil {
+			counts[hashStr(cell.Hash())]++
+			n++
+		}
+	}
+	return counts, n
+}
+
+// cellsEqual returns true if the cells of two rows in matching columns are
+// equal, cells missing from both rows are equal
+func cellsEqual(a *tableSide, ra, ca int, b *tableSide, rb, cb int) bool {
+	acell, bcell := a.cell(ra, ca), b.cell(rb, cb)
+	if acell == nil || bcell == nil {
+		return acell == bcell
+	}
+	return hashStr(acell.Hash()) == hashStr(bcell.Hash())
+}
+
+// tableSimilarity is the share of values columns & rows need in common to be
+// matched
+func (d *diff) tableSimilarity() float64 {
+	if d.similarityThreshold > 0 {
+		return d.similarityThreshold
+	}
+	return alignSimilarity
+}
+
+// tableMatch matches the rows & cells of two tables, returning a description
+// of changes between them. Columns are matched by header name, then by the
+// values they hold. Rows are matched by key when a key column is set, or
+// aligned as sequences otherwise. Rows & columns that move relative to others
+// aren't matched, because deltas can't describe a move
+func (d *diff) tableMatch(t1, t2 *array) (*TableDiff, error) {
+	a, b := d.tableSide(t1), d.tableSide(t2)
+	keyA, keyB := -1, -1
+	if d.tableKey != "" {
+		keyA, keyB = columnIndex(a.names, d.tableKey), columnIndex(b.names, d.tableKey)
+		if keyA < 0 || keyB < 0 {
+			return nil, fmt.Errorf("key column %q not found", d.tableKey)
+		}
+	}
+
+	bCols, colMoved := d.matchColumns(a, b)
+	var rows [][2]int
+	if keyA >= 0 {
+		rows = matchKeyedRows(a, b, keyA, keyB)
+	} else {
+		rows = d.matchRows(a, b, bCols)
+	}
+	rowMoved := moves(rows)
+
+	d.setMatch(t1, t2)
+	d.setMatch(t2, t1)
+	if a.start > 0 && b.start > 0 {
+		d.matchRow(a, b, 0, 0, bCols, colMoved)
+	}
+	for i, p := range rows {
+		if !rowMoved[i] {
+			d.matchRow(a, b, p[0], p[1], bCols, colMoved)
+		}
+	}
+
+	td := &TableDiff{}
+	aCols := make([]bool, len(a.names))
+	for j, i := range bCols {
+		col := &ColumnDelta{Type: DTContext, Name: b.names[j], Index: j, SourceIndex: i, Moved: colMoved[j]}
+		if i < 0 {
+			col.Type = DTInsert
+		} else {
+			aCols[i] = true
+			if d.tableHeader && a.names[i] != b.names[j] {
+				col.Type = DTRename
+				col.SourceName = a.names[i]
+			}
+		}
+		td.Columns = append(td.Columns, col)
+	}
+	for i, matched := range aCols {
+		if !matched {
+			td.Columns = append(td.Columns, &ColumnDelta{Type: DTDelete, Name: a.names[i], Index: -1, SourceIndex: i})
+		}
+	}
+
+	key := func(s *tableSide, row, col int) interface{} {
+		if col < 0 {
+			return nil
+		}
+		return s.cellValue(row, col)
+	}
+	aRows := make([]bool, len(a.rows))
+	bRows := make([]int, len(b.rows))
+	for i := range bRows {
+		bRows[i] = -1
+	}
+	for i, p := range rows {
+		aRows[p[0]] = true
+		bRows[p[1]] = i
+	}
+	nextDelete := a.start
+	deletes := func(before int) {
+		for ; nextDelete < before; nextDelete++ {
+			if !aRows[nextDelete] {
+				td.Rows = append(td.Rows, &RowDelta{Type: DTDelete, Index: -1, SourceIndex: nextDelete, Key: key(a, nextDelete, keyA), Value: a.rows[nextDelete].Value()})
+			}
+		}
+	}
+	// rows of a that were deleted before the next row of b that's in place
+	kept := make([]int, len(b.rows)+1)
+	kept[len(b.rows)] = len(a.rows)
+	for rb := len(b.rows) - 1; rb >= 0; rb-- {
+		kept[rb] = kept[rb+1]
+		if i := bRows[rb]; i >= 0 && !rowMoved[i] {
+			kept[rb] = rows[i][0]
+		}
+	}
+	for rb := b.start; rb < len(b.rows); rb++ {
+		deletes(kept[rb])
+		i := bRows[rb]
+		if i < 0 {
+			td.Rows = append(td.Rows, &RowDelta{Type: DTInsert, Index: rb, SourceIndex: -1, Key: key(b, rb, keyB), Value: b.rows[rb].Value()})
+			continue
+		}
+
+		ra := rows[i][0]
+		row := &RowDelta{Type: DTContext, Index: rb, SourceIndex: ra, Key: key(b, rb, keyB), Moved: rowMoved[i]}
+		for cb, ca := range bCols {
+			if ca >= 0 && !cellsEqual(a, ra, ca, b, rb, cb) {
+				row.Type = DTUpdate
+				row.Cells = append(row.Cells, &CellDelta{Column: b.names[cb], Index: cb, Value: b.cellValue(rb, cb), SourceValue: a.cellValue(ra, ca)})
+			}
+		}
+		if row.Type != DTContext || row.Moved {
+			td.Rows = append(td.Rows, row)
+		}
+	}
+	deletes(len(a.rows))
+
+	return td, nil
+}
+
+// columnIndex finds the first column with a name, -1 if there isn't one
+func columnIndex(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// matchColumns pairs columns of a & b, returning the matching column of a for
+// each column of b, -1 for inserted columns, & which columns of b moved.
+// Columns with the same header name match first. Remaining columns match the
+// most similar column by the values they hold. Tables without a header also
+// match remaining columns by position when the same number of columns sit
+// between matched columns in both tables
+func (d *diff) matchColumns(a, b *tableSide) (bCols []int, moved []bool) {
+	aCols := make([]int, len(a.names))
+	for i := range aCols {
+		aCols[i] = -1
+	}
+	bCols = make([]int, len(b.names))
+	for j := range bCols {
+		bCols[j] = -1
+	}
+	pair := func(i, j int) {
+		aCols[i] = j
+		bCols[j] = i
+	}
+
+	if d.tableHeader {
+		for j, name := range b.names {
+			for i, aname := range a.names {
+				if aCols[i] < 0 && aname == name {
+					pair(i, j)
+					break
+				}
+			}
+		}
+	}
+
+	threshold := d.tableSimilarity()
+	for j := range bCols {
+		if bCols[j] >= 0 {
+			continue
+		}
+		bcounts, bn := b.column(j)
+		best, score := -1, 0.0
+		for i := range aCols {
+			if aCols[i] >= 0 {
+				continue
+			}
+			acounts, an := a.column(i)
+			if s := countSimilarity(acounts, an, bcounts, bn); s >= threshold && s > score {
+				best, score = i, s
+			}
+		}
+		if best >= 0 {
+			pair(best, j)
+		}
+	}
+
+	if !d.tableHeader {
+		prev := -1
+		for j := 0; j < len(bCols); {
+			if bCols[j] >= 0 {
+				prev = bCols[j]
+				j++
+				continue
+			}
+			k := j
+			for k < len(bCols) && bCols[k] < 0 {
+				k++
+			}
+			next := len(aCols)
+			if k < len(bCols) {
+				next = bCols[k]
+			}
+			var unmatched []int
+			for i := prev + 1; i < next; i++ {
+				if aCols[i] < 0 {
+					unmatched = append(unmatched, i)
+				}
+			}
+			if len(unmatched) == k-j {
+				for x, i := range unmatched {
+					pair(i, j+x)
+				}
+			}
+			j = k
+		}
+	}
+
+	var pairs [][2]int
+	for j, i := range bCols {
+		if i >= 0 {
+			pairs = append(pairs, [2]int{i, j})
+		}
+	}
+	moved = make([]bool, len(bCols))
+	for x, mv := range moves(pairs) {
+		moved[pairs[x][1]] = mv
+	}
+	return bCols, moved
+}
+
+// countSimilarity scores how many values two columns share on a scale of 0
+// to 1, ignoring the order of values
+func countSimilarity(a map[string]int, an int, b map[string]int, bn int) float64 {
+	if an+bn == 0 {
+		return 0
+	}
+	shared := 0
+	for key, count := range b {
+		if ac := a[key]; ac < count {
+			shared += ac
+		} else {
+			shared += count
+		}
+	}
+	return float64(2*shared) / float64(an+bn)
+}
+
+// maxTableEdits bounds the number of differences row alignment will search
+// for before giving up. Myers uses O(D^2) space for D differences
+const maxTableEdits = 1024
+
+// matchKeyedRows pairs data rows of a & b with equal values in their key
+// columns, in order of b. Rows with repeated keys pair in order
+func matchKeyedRows(a, b *tableSide, keyA, keyB int) (rows [][2]int) {
+	keyStr := func(s *tableSide, row, col int) string {
+		if cell := s.cell(row, col); cell != nil {
+			return hashStr(cell.Hash())
+		}
+		return ""
+	}
+	byKey := map[string][]int{}
+	for ra := a.start; ra < len(a.rows); ra++ {
+		key := keyStr(a, ra, keyA)
+		byKey[key] = append(byKey[key], ra)
+	}
+	for rb := b.start; rb < len(b.rows); rb++ {
+		key := keyStr(b, rb, keyB)
+		if found := byKey[key]; len(found) > 0 {
+			rows = append(rows, [2]int{found[0], rb})
+			byKey[key] = found[1:]
+		}
+	}
+	return rows
+}
+
+// matchRows aligns data rows of a & b as sequences of rows, comparing cells in
+// matched columns. Runs of deleted & inserted rows between equal rows are
+// paired in order when enough of their cells are equal. Tables that differ in
+// more than maxTableEdits rows are too costly to align, and only pair equal
+// rows, in the order they appear
+func (d *diff) matchRows(a, b *tableSide, bCols []int) (rows [][2]int) {
+	rowKey := func(s *tableSide, row int, cols func(j int) int) string {
+		buf := &strings.Builder{}
+		for j := range bCols {
+			if col := cols(j); col >= 0 {
+				if cell := s.cell(row, col); cell != nil {
+					buf.WriteString(hashStr(cell.Hash()))
+				}
+			}
+			buf.WriteByte(',')
+		}
+		return buf.String()
+	}
+	aKeys := make([]string, len(a.rows)-a.start)
+	for i := range aKeys {
+		aKeys[i] = rowKey(a, a.start+i, func(j int) int { return bCols[j] })
+	}
+	bKeys := make([]string, len(b.rows)-b.start)
+	for i := range bKeys {
+		bKeys[i] = rowKey(b, b.start+i, func(j int) int {
+			if bCols[j] < 0 {
+				return -1
+			}
+			return j
+		})
+	}
+
+	similar := func(ra, rb int) bool {
+		matched, equal := 0, 0
+		for cb, ca := range bCols {
+			if ca >= 0 {
+				matched++
+				if cellsEqual(a, ra, ca, b, rb, cb) {
+					equal++
+				}
+			}
+		}
+		return matched > 0 && float64(equal)/float64(matched) >= d.tableSimilarity()
+	}
+
+	var dels, ins []int
+	flush := func() {
+		next := 0
+		for _, rb := range ins {
+			for x := next; x < len(dels); x++ {
+				if similar(dels[x], rb) {
+					rows = append(rows, [2]int{dels[x], rb})
+					next = x + 1
+					break
+				}
+			}
+		}
+		dels, ins = dels[:0], ins[:0]
+	}
+	edits, ok := boundedMyers(len(aKeys), len(bKeys), maxTableEdits, func(i, j int) bool { return aKeys[i] == bKeys[j] })
+	if !ok {
+		byKey := map[string][]int{}
+		for i, key := range aKeys {
+			byKey[key] = append(byKey[key], a.start+i)
+		}
+		for i, key := range bKeys {
+			if found := byKey[key]; len(found) > 0 {
+				rows = append(rows, [2]int{found[0], b.start + i})
+				byKey[key] = found[1:]
+			}
+		}
+		return rows
+	}
+	for _, e := range edits {
+		switch e.op {
+		case DTContext:
+			flush()
+			rows = append(rows, [2]int{a.start + e.aIdx, b.start + e.bIdx})
+		case DTDelete:
+			dels = append(dels, a.start+e.aIdx)
+		case DTInsert:
+			ins = append(ins, b.start+e.bIdx)
+		}
+	}
+	flush()
+	return rows
+}
+
+// moves takes pairs of indices in order of the second index, & returns which
+// pairs need to move for the first indices to be in order as well, keeping as
+// many pairs in place as possible. Pairs kept in place are a longest
+// increasing subsequence of first indices, found in O(n log n) time. Of equally
+// long subsequences, the one keeping earlier pairs wins
+func moves(pairs [][2]int) []bool {
+	// search from the last pair back for the longest decreasing run. tails[l]
+	// is the pair starting the run of length l+1 with the largest first index
+	// found so far, next links each pair to the pair after it in its run
+	tails := make([]int, 0, len(pairs))
+	next := make([]int, len(pairs))
+	for x := len(pairs) - 1; x >= 0; x-- {
+		l := sort.Search(len(tails), func(i int) bool { return pairs[tails[i]][0] <= pairs[x][0] })
+		next[x] = -1
+		if l > 0 {
+			next[x] = tails[l-1]
+		}
+		if l == len(tails) {
+			tails = append(tails, x)
+		} else {
+			tails[l] = x
+		}
+	}
+
+	moved := make([]bool, len(pairs))
+	for x := range moved {
+		moved[x] = true
+	}
+	if len(tails) > 0 {
+		for x := tails[len(tails)-1]; x >= 0; x = next[x] {
+			moved[x] = false
+		}
+	}
+	return moved
+}
+
+// matchRow matches two rows & their cells in matched columns that haven't
+// moved
+func (d *diff) matchRow(a, b *tableSide, ra, rb int, bCols []int, colMoved []bool) {
+	d.setMatch(a.rows[ra], b.rows[rb])
+	d.setMatch(b.rows[rb], a.rows[ra])
+	for cb, ca := range bCols {
+		if ca < 0 || colMoved[cb] {
+			continue
+		}
+		acell, bcell := a.cell(ra, ca), b.cell(rb, cb)
+		if acell == nil || bcell == nil || !mappable(acell, bcell) {
+			continue
+		}
+		d.setMatch(acell, bcell)
+		d.setMatch(bcell, acell)
+		walk(bcell, nil, func(_ []Addr, n node) bool {
+			d.propagateMatchToChildren(n)
+			return true
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package deepdiff
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestDiffTable(t *testing.T) {
+	header := func(cfg *Config) { cfg.TableHeader = true }
+	cases := []struct {
+		description string
+		src, dst    string
+		opts        []DiffOption
+		expect      *TableDiff
+	}{
+		{
+			"inserted column",
+			`[["id","name"],[1,"a"],[2,"b"],[3,"c"]]`,
+			`[["id","name","age"],[1,"a",10],[2,"b",20],[3,"c",30]]`,
+			[]DiffOption{header},
+			&TableDiff{
+				Columns: []*ColumnDelta{
+					{Type: DTContext, Name: "id", Index: 0, SourceIndex: 0},
+					{Type: DTContext, Name: "name", Index: 1, SourceIndex: 1},
+					{Type: DTInsert, Name: "age", Index: 2, SourceIndex: -1},
+				},
+			},
+		},
+		{
+			"deleted column without a header",
+			`[[1,"x","a"],[2,"y","b"],[3,"z","c"]]`,
+			`[[1,"a"],[2,"b"],[3,"c"]]`,
+			nil,
+			&TableDiff{
+				Columns: []*ColumnDelta{
+					{Type: DTContext, Name: "0", Index: 0, SourceIndex: 0},
+					{Type: DTContext, Name: "1", Index: 1, SourceIndex: 2},
+					{Type: DTDelete, Name: "1", Index: -1, SourceIndex: 1},
+				},
+			},
+		},
+		{
+			"renamed & moved column",
+			`[["id","name","n"],[1,"a",5],[2,"b",6],[3,"c",7]]`,
+			`[["id","n","label"],[1,5,"a"],[2,6,"B"],[3,7,"c"]]`,
+			[]DiffOption{header},
+			&TableDiff{
+				Columns: []*ColumnDelta{
+					{Type: DTContext, Name: "id", Index: 0, SourceIndex: 0},
+					{Type: DTContext, Name: "n", Index: 1, SourceIndex: 2},
+					{Type: DTRename, Name: "label", SourceName: "name", Index: 2, SourceIndex: 1, Moved: true},
+				},
+				Rows: []*RowDelta{
+					{Type: DTUpdate, Index: 2, SourceIndex: 2, Cells: []*CellDelta{
+						{Column: "label", Index: 2, Value: "B", SourceValue: "b"},
+					}},
+				},
+			},
+		},
+		{
+			"aligned rows",
+			`[["id","name"],[1,"a"],[2,"b"],[3,"c"]]`,
+			`[["id","name"],[1,"a"],[2,"bb"],[4,"d"]]`,
+			[]DiffOption{header},
+			&TableDiff{
+				Columns: []*ColumnDelta{
+					{Type: DTContext, Name: "id", Index: 0, SourceIndex: 0},
+					{Type: DTContext, Name: "name", Index: 1, SourceIndex: 1},
+				},
+				Rows: []*RowDelta{
+					{Type: DTUpdate, Index: 2, SourceIndex: 2, Cells: []*CellDelta{
+						{Column: "name", Index: 1, Value: "bb", SourceValue: "b"},
+					}},
+					{Type: DTDelete, Index: -1, SourceIndex: 3, Value: []interface{}{float64(3), "c"}},
+					{Type: DTInsert, Index: 3, SourceIndex: -1, Value: []interface{}{float64(4), "d"}},
+				},
+			},
+		},
+		{
+			"keyed rows",
+			`[["id","name"],[1,"a"],[2,"b"],[3,"c"]]`,
+			`[["id","name"],[3,"c"],[1,"a"],[2,"bb"],[4,"d"]]`,
+			[]DiffOption{header, func(cfg *Config) { cfg.TableKey = "id" }},
+			&TableDiff{
+				Columns: []*ColumnDelta{
+					{Type: DTContext, Name: "id", Index: 0, SourceIndex: 0},
+					{Type: DTContext, Name: "name", Index: 1, SourceIndex: 1},
+				},
+				Rows: []*RowDelta{
+					{Type: DTContext, Index: 1, SourceIndex: 3, Key: float64(3), Moved: true},
+					{Type: DTUpdate, Index: 3, SourceIndex: 2, Key: float64(2), Cells: []*CellDelta{
+						{Column: "name", Index: 1, Value: "bb", SourceValue: "b"},
+					}},
+					{Type: DTInsert, Index: 4, SourceIndex: -1, Key: float64(4), Value: []interface{}{float64(4), "d"}},
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			var src, dst interface{}
+			if err := json.Unmarshal([]byte(c.src), &src); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(c.dst), &dst); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := New(c.opts...).DiffTable(context.Background(), src, dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.expect, got, cmpopts.IgnoreUnexported(TableDiff{})); diff != "" {
+				t.Errorf("table diff mismatch (-want +got):\n%s", diff)
+			}
+			if err := checkPatch(got.Deltas(), src, dst); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestTabularDiff(t *testing.T) {
+	cases := []TestCase{
+		{
+			"inserted column",
+			`[["id","name"],[1,"a"],[2,"b"]]`,
+			`[["id","age","name"],[1,10,"a"],[2,20,"b"]]`,
+			Deltas{
+				{Type: DTContext, Path: IndexAddr(0), Deltas: Deltas{
+					{Type: DTContext, Path: IndexAddr(0), Value: "id"},
+					{Type: DTInsert, Path: IndexAddr(1), Value: "age"},
+					{Type: DTContext, Path: IndexAddr(2), Value: "name"},
+				}},
+				{Type: DTContext, Path: IndexAddr(1), Deltas: Deltas{
+					{Type: DTContext, Path: IndexAddr(0), Value: float64(1)},
+					{Type: DTInsert, Path: IndexAddr(1), Value: float64(10)},
+					{Type: DTContext, Path: IndexAddr(2), Value: "a"},
+				}},
+				{Type: DTContext, Path: IndexAddr(2), Deltas: Deltas{
+					{Type: DTContext, Path: IndexAddr(0), Value: float64(2)},
+					{Type: DTInsert, Path: IndexAddr(1), Value: float64(20)},
+					{Type: DTContext, Path: IndexAddr(2), Value: "b"},
+				}},
+			},
+		},
+		{
+			"values that aren't tables",
+			`{"a":[[1]]}`,
+			`{"a":[[2]]}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("a"), Deltas: Deltas{
+					{Type: DTContext, Path: IndexAddr(0), Deltas: Deltas{
+						{Type: DTDelete, Path: IndexAddr(0), Value: float64(1)},
+						{Type: DTInsert, Path: IndexAddr(0), Value: float64(2)},
+					}},
+				}},
+			},
+		},
+	}
+
+	RunTestCases(t, cases, func(cfg *Config) {
+		cfg.Tabular = true
+		cfg.TableHeader = true
+	})
+}
+
+func TestDiffTableErrors(t *testing.T) {
+	ctx := context.Background()
+	table := []interface{}{[]interface{}{"id"}, []interface{}{1}}
+
+	if _, err := New().DiffTable(ctx, map[string]interface{}{}, table); err == nil {
+		t.Error("expected an error diffing an object")
+	}
+	if _, err := New().DiffTable(ctx, table, []interface{}{1}); err == nil {
+		t.Error("expected an error diffing an array of scalars")
+	}
+	if _, err := New(func(cfg *Config) { cfg.TableHeader = true; cfg.TableKey = "missing" }).DiffTable(ctx, table, table); err == nil {
+		t.Error("expected an error for a missing key column")
+	}
+}
+
+func TestMoves(t *testing.T) {
+	cases := []struct {
+		pairs  [][2]int
+		expect []bool
+	}{
+		{nil, []bool{}},
+		{[][2]int{{0, 0}, {1, 1}, {2, 2}}, []bool{false, false, false}},
+		{[][2]int{{2, 0}, {1, 1}, {0, 2}}, []bool{false, true, true}},
+		{[][2]int{{0, 0}, {2, 1}, {1, 2}}, []bool{false, false, true}},
+		{[][2]int{{3, 0}, {0, 1}, {1, 2}, {4, 3}, {2, 4}}, []bool{true, false, false, false, true}},
+	}
+	for _, c := range cases {
+		if diff := cmp.Diff(c.expect, moves(c.pairs)); diff != "" {
+			t.Errorf("%v: result mismatch (-want +got):\n%s", c.pairs, diff)
+		}
+	}
+}
+
+// TestDiffTableReordered diffs tables too different to align row by row, which
+// pair equal rows instead
+func TestDiffTableReordered(t *testing.T) {
+	var a, b []interface{}
+	for i := 0; i < 3000; i++ {
+		a = append(a, []interface{}{float64(i), "row"})
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		if i%10 != 0 {
+			b = append(b, a[i])
+		}
+	}
+	b = append(b, []interface{}{float64(-1), "new"})
+
+	for _, key := range []string{"", "0"} {
+		td, err := New(func(cfg *Config) { cfg.TableKey = key }).DiffTable(context.Background(), a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		moved := 0
+		for _, row := range td.Rows {
+			if row.Moved {
+				moved++
+			}
+		}
+		// one row of those kept stays in place
+		if expect := len(b) - 2; moved != expect {
+			t.Errorf("key %q: expected %d moved rows, got %d", key, expect, moved)
+		}
+		if err := checkPatch(td.Deltas(), a, b); err != nil {
+			t.Errorf("key %q: %s", key, err)
+		}
+	}
+}
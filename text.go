@@ -0,0 +1,113 @@
+package deepdiff
+
+import "strings"
+
+// TextDiffMode determines how strings are split into tokens when calculating
+// text diffs
+type TextDiffMode uint8
+
+const (
+	// TextDiffChars compares strings character by character
+	TextDiffChars TextDiffMode = iota
+	// TextDiffLines compares strings line by line, best suited to multiline
+	// text like embedded queries or source code
+	TextDiffLines
+)
+
+// maxTextEdits bounds the number of differences a text diff will search for
+// before giving up. Myers uses O(D^2) space for D differences, so very
+// different strings are reported as plain updates instead
+const maxTextEdits = 1024
+
+// TextEdit is a span of text within a text diff
+type TextEdit struct {
+	// the type of change, one of DTContext, DTDelete, or DTInsert
+	Type Operation `json:"type"`
+	// the text this edit spans
+	Text string `json:"text"`
+}
+
+// TextDiff is an edit script for turning one string into another. Context
+// and deleted edits concatenate to the source string, context and inserted
+// edits concatenate to the destination string
+type TextDiff []TextEdit
+
+// textDiff calculates a text diff for two string values, returning nil if
+// either value isn't a string, both strings are shorter than the configured
+// threshold, or the strings differ in more than maxTextEdits tokens
+func (d *diff) textDiff(src, dst interface{}) TextDiff {
+	a, ok := src.(string)
+	if !ok {
+		return nil
+	}
+	b, ok := dst.(string)
+	if !ok {
+		return nil
+	}
+	if len(a) < d.textThreshold && len(b) < d.textThreshold {
+		return nil
+	}
+
+	return diffText(a, b, d.textMode)
+}
+
+// diffText calculates the text diff of a & b, tokenized according to mode.
+// diffText returns nil if a & b differ in more than maxTextEdits tokens
+func diffText(a, b string, mode TextDiffMode) TextDiff {
+	at := tokenize(a, mode)
+	bt := tokenize(b, mode)
+	edits, ok := boundedMyers(len(at), len(bt), maxTextEdits, func(i, j int) bool { return at[i] == bt[j] })
+	if !ok {
+		return nil
+	}
+
+	// group edits into runs, writing all deletes of a changed run before its
+	// inserts
+	var (
+		td            TextDiff
+		ctx, del, ins strings.Builder
+	)
+	flushContext := func() {
+		if ctx.Len() > 0 {
+			td = append(td, TextEdit{Type: DTContext, Text: ctx.String()})
+			ctx.Reset()
+		}
+	}
+	flushChanges := func() {
+		if del.Len() > 0 {
+			td = append(td, TextEdit{Type: DTDelete, Text: del.String()})
+			del.Reset()
+		}
+		if ins.Len() > 0 {
+			td = append(td, TextEdit{Type: DTInsert, Text: ins.String()})
+			ins.Reset()
+		}
+	}
+	for _, e := range edits {
+		switch e.op {
+		case DTContext:
+			flushChanges()
+			ctx.WriteString(at[e.aIdx])
+		case DTDelete:
+			flushContext()
+			del.WriteString(at[e.aIdx])
+		case DTInsert:
+			flushContext()
+			ins.WriteString(bt[e.bIdx])
+		}
+	}
+	flushContext()
+	flushChanges()
+	return td
+}
+
+func tokenize(s string, mode TextDiffMode) []string {
+	if mode == TextDiffLines {
+		return strings.SplitAfter(s, "\n")
+	}
+	tokens := make([]string, 0, len(s))
+	for _, r := range s {
+		tokens = append(tokens, string(r))
+	}
+	return tokens
+}
@@ -0,0 +1,209 @@
+package deepdiff
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffText(t *testing.T) {
+	cases := []struct {
+		description string
+		a, b        string
+		mode        TextDiffMode
+		expect      TextDiff
+	}{
+		{"identical", "apples", "apples", TextDiffChars,
+			TextDiff{{Type: DTContext, Text: "apples"}},
+		},
+		{"empty source", "", "apples", TextDiffChars,
+			TextDiff{{Type: DTInsert, Text: "apples"}},
+		},
+		{"single char change", "apples and oranges", "apples and orangez", TextDiffChars,
+			TextDiff{
+				{Type: DTContext, Text: "apples and orange"},
+				{Type: DTDelete, Text: "s"},
+				{Type: DTInsert, Text: "z"},
+			},
+		},
+		{"word swap", "the quick brown fox", "the slow brown fox", TextDiffChars,
+			TextDiff{
+				{Type: DTContext, Text: "the "},
+				{Type: DTDelete, Text: "quick"},
+				{Type: DTInsert, Text: "slow"},
+				{Type: DTContext, Text: " brown fox"},
+			},
+		},
+		{"multibyte characters", "café olé", "cafe olé", TextDiffChars,
+			TextDiff{
+				{Type: DTContext, Text: "caf"},
+				{Type: DTDelete, Text: "é"},
+				{Type: DTInsert, Text: "e"},
+				{Type: DTContext, Text: " olé"},
+			},
+		},
+		{"lines",
+			"SELECT *\nFROM users\nWHERE id = 1\n",
+			"SELECT *\nFROM accounts\nWHERE id = 1\nLIMIT 1\n",
+			TextDiffLines,
+			TextDiff{
+				{Type: DTContext, Text: "SELECT *\n"},
+				{Type: DTDelete, Text: "FROM users\n"},
+				{Type: DTInsert, Text: "FROM accounts\n"},
+				{Type: DTContext, Text: "WHERE id = 1\n"},
+				{Type: DTInsert, Text: "LIMIT 1\n"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			got := diffText(c.a, c.b, c.mode)
+			if diff := cmp.Diff(c.expect, got); diff != "" {
+				t.Errorf("result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDiffTextLimit(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte('a' + r.Intn(26))
+		}
+		return string(b)
+	}
+
+	// strings this different are too costly to diff, & report plain updates
+	if got := diffText(random(20000), random(20000), TextDiffChars); got != nil {
+		t.Errorf("expected nil text diff for strings past the edit limit, got %d edits", len(got))
+	}
+
+	a := random(20000)
+	b := a[:10000] + "changed" + a[10000:]
+	expect := TextDiff{
+		{Type: DTContext, Text: a[:10000]},
+		{Type: DTInsert, Text: "changed"},
+		{Type: DTContext, Text: a[10000:]},
+	}
+	if diff := cmp.Diff(expect, diffText(a, b, TextDiffChars)); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestMyersMinimal checks myers edit scripts against the length of a longest
+// common subsequence on random inputs
+func TestMyersMinimal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	randSeq := func() []byte {
+		seq := make([]byte, rnd.Intn(20))
+		for i := range seq {
+			seq[i] = "abc"[rnd.Intn(3)]
+		}
+		return seq
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randSeq(), randSeq()
+		edits := myers(len(a), len(b), func(i, j int) bool { return a[i] == b[j] })
+
+		var src, dst []byte
+		common := 0
+		for _, e := range edits {
+			switch e.op {
+			case DTContext:
+				if a[e.aIdx] != b[e.bIdx] {
+					t.Fatalf("%q -> %q: context edit pairs unequal elements", a, b)
+				}
+				src = append(src, a[e.aIdx])
+				dst = append(dst, b[e.bIdx])
+				common++
+			case DTDelete:
+				src = append(src, a[e.aIdx])
+			case DTInsert:
+				dst = append(dst, b[e.bIdx])
+			}
+		}
+		if string(src) != string(a) || string(dst) != string(b) {
+			t.Fatalf("%q -> %q: edits don't reconstruct inputs. got %q -> %q", a, b, src, dst)
+		}
+		if lcs := lcsLength(a, b); common != lcs {
+			t.Fatalf("%q -> %q: expected %d common elements, got %d", a, b, lcs, common)
+		}
+	}
+}
+
+func lcsLength(a, b []byte) int {
+	c := make([][]int, len(a)+1)
+	for i := range c {
+		c[i] = make([]int, len(b)+1)
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				c[i][j] = c[i-1][j-1] + 1
+			} else if c[i-1][j] > c[i][j-1] {
+				c[i][j] = c[i-1][j]
+			} else {
+				c[i][j] = c[i][j-1]
+			}
+		}
+	}
+	return c[len(a)][len(b)]
+}
+
+func TestTextDiffs(t *testing.T) {
+	src := strings.Repeat("a long description. ", 3)
+	dst := strings.Repeat("a long description. ", 2) + "a short description. "
+
+	cases := []TestCase{
+		{
+			"long string update",
+			`{"a":"` + src + `","b":"short","c":true}`,
+			`{"a":"` + dst + `","b":"shirt","c":true}`,
+			Deltas{
+				{Type: DTUpdate, Path: StringAddr("a"), SourceValue: src, Value: dst, TextDiff: TextDiff{
+					{Type: DTContext, Text: strings.Repeat("a long description. ", 2) + "a "},
+					{Type: DTDelete, Text: "l"},
+					{Type: DTInsert, Text: "sh"},
+					{Type: DTContext, Text: "o"},
+					{Type: DTDelete, Text: "ng"},
+					{Type: DTInsert, Text: "rt"},
+					{Type: DTContext, Text: " description. "},
+				}},
+				{Type: DTUpdate, Path: StringAddr("b"), SourceValue: "short", Value: "shirt"},
+				{Type: DTContext, Path: StringAddr("c"), Value: true},
+			},
+		},
+	}
+
+	RunTestCases(t, cases, func(c *Config) {
+		c.CalcChanges = true
+		c.TextDiffThreshold = 20
+	})
+}
+
+func TestTextDiffJSON(t *testing.T) {
+	ds := Deltas{
+		{Type: DTUpdate, Path: StringAddr("a"), Value: "the slow fox", TextDiff: TextDiff{
+			{Type: DTContext, Text: "the "},
+			{Type: DTDelete, Text: "quick"},
+			{Type: DTInsert, Text: "slow"},
+			{Type: DTContext, Text: " fox"},
+		}},
+	}
+
+	data, err := json.Marshal(ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `[["~","a","the slow fox",{"textDiff":[[" ","the "],["-","quick"],["+","slow"],[" "," fox"]]}]]`
+	if string(data) != expect {
+		t.Errorf("json mismatch.\nwant: %s\ngot:  %s", expect, string(data))
+	}
+}
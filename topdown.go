@@ -0,0 +1,214 @@
+package deepdiff
+
+import "bytes"
+
+// topDown maps two trees from the root down. Nodes can only match if their
+// parents match, object values only match values of the same key, and array
+// elements match in order. This is not a tree edit distance like Zhang &
+// Shasha or APTED: a value moved to a new parent is a delete & an insert.
+// Mappings are scored by dynamic programming, where deleting or inserting a
+// subtree costs one per node, and updating a scalar costs the same as
+// deleting & inserting it. The mapping is written to diff state, ready for
+// calcDeltas
+type topDown struct {
+	d    *diff
+	memo map[[2]int]int
+}
+
+// topDownMatch matches t1 & t2 with a top-down mapping. ties between
+// matching & replacing a subtree prefer matching
+func (d *diff) topDownMatch(t1, t2 node) {
+	e := &topDown{d: d, memo: map[[2]int]int{}}
+	// scalar roots are always replaced, there's no path to update them at
+	if _, ok := t1.(compound); ok && mappable(t1, t2) && e.cost(t1, t2) <= replace(t1, t2) {
+		e.match(t1, t2)
+	}
+}
+
+// size is the number of nodes in a subtree
+func size(n node) int {
+	if cmp, ok := n.(compound); ok {
+		return 1 + cmp.DescendantsCount()
+	}
+	return 1
+}
+
+// mappable checks if two nodes can be matched. scalars can match any scalar,
+// compound nodes can only match nodes of the same type
+func mappable(a, b node) bool {
+	_, acmp := a.(compound)
+	_, bcmp := b.(compound)
+	if acmp || bcmp {
+		return a.Type() == b.Type()
+	}
+	return true
+}
+
+// replace is the cost of deleting a & inserting b
+func replace(a, b node) int {
+	return size(a) + size(b)
+}
+
+// cost calculates the cheapest script that turns a into b, given a & b are
+// matched
+func (e *topDown) cost(a, b node) int {
+	key := [2]int{a.index(), b.index()}
+	if c, ok := e.memo[key]; ok {
+		return c
+	}
+
+	c := 0
+	switch x := a.(type) {
+	case *scalar:
+		if !bytes.Equal(a.Hash(), b.Hash()) {
+			c = replace(a, b)
+		}
+	case *object:
+		y := b.(*object)
+		for _, ach := range x.children {
+			if bch := y.Child(ach.Addr()); bch != nil {
+				c += e.childCost(ach, bch)
+			} else {
+				c += size(ach)
+			}
+		}
+		for _, bch := range y.children {
+			if x.Child(bch.Addr()) == nil {
+				c += size(bch)
+			}
+		}
+	case *array:
+		y := b.(*array)
+		if x.mode != ArrayOrdered || y.mode != ArrayOrdered {
+			c = unorderedCost(x, y)
+		} else {
+			table := e.align(x.children, y.children)
+			c = table[len(x.children)][len(y.children)]
+		}
+	}
+
+	e.memo[key] = c
+	return c
+}
+
+// childCost is the cheapest way to turn a into b, by either matching or
+// replacing them. ties prefer matching
+func (e *topDown) childCost(a, b node) int {
+	if mappable(a, b) {
+		if c := e.cost(a, b); c <= replace(a, b) {
+			return c
+		}
+	}
+	return replace(a, b)
+}
+
+// align builds a table of the cheapest scripts between every prefix of two
+// child lists. table[i][j] is the cost of turning a[:i] into b[:j]
+func (e *topDown) align(a, b []node) [][]int {
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+		if i > 0 {
+			table[i][0] = table[i-1][0] + size(a[i-1])
+		}
+	}
+	for j := 1; j <= len(b); j++ {
+		table[0][j] = table[0][j-1] + size(b[j-1])
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			c := table[i-1][j] + size(a[i-1])
+			if ins := table[i][j-1] + size(b[j-1]); ins < c {
+				c = ins
+			}
+			if mappable(a[i-1], b[j-1]) {
+				if m := table[i-1][j-1] + e.cost(a[i-1], b[j-1]); m < c {
+					c = m
+				}
+			}
+			table[i][j] = c
+		}
+	}
+	return table
+}
+
+// unorderedCost is the cost of turning one unordered array into another.
+// elements are paired by hash, unpaired elements are deleted or inserted.
+// sets only pay for elements that don't appear in the other array at all
+func unorderedCost(a, b *array) int {
+	mode := a.mode
+	if mode == ArrayOrdered {
+		mode = b.mode
+	}
+
+	// equal hashes have equal sizes, tally counts & a size for each hash
+	type tally struct{ a, b, size int }
+	tallies := map[string]*tally{}
+	count := func(n node) *tally {
+		key := hashStr(n.Hash())
+		t := tallies[key]
+		if t == nil {
+			t = &tally{size: size(n)}
+			tallies[key] = t
+		}
+		return t
+	}
+	for _, ch := range a.children {
+		count(ch).a++
+	}
+	for _, ch := range b.children {
+		count(ch).b++
+	}
+
+	c := 0
+	for _, t := range tallies {
+		switch {
+		case mode == ArraySet && t.a > 0 && t.b > 0:
+		case t.a > t.b:
+			c += (t.a - t.b) * t.size
+		default:
+			c += (t.b - t.a) * t.size
+		}
+	}
+	return c
+}
+
+// match writes the mapping of a & b to diff state
+func (e *topDown) match(a, b node) {
+	d := e.d
+	d.setMatch(a, b)
+	d.setMatch(b, a)
+
+	switch x := a.(type) {
+	case *object:
+		y := b.(*object)
+		for _, ach := range x.children {
+			if bch := y.Child(ach.Addr()); bch != nil && mappable(ach, bch) && e.cost(ach, bch) <= replace(ach, bch) {
+				e.match(ach, bch)
+			}
+		}
+	case *array:
+		y := b.(*array)
+		if x.mode != ArrayOrdered || y.mode != ArrayOrdered {
+			d.matchUnorderedChildren(x, y)
+			return
+		}
+
+		// walk the alignment table back from the end, preferring matches
+		table := e.align(x.children, y.children)
+		for i, j := len(x.children), len(y.children); i > 0 || j > 0; {
+			switch {
+			case i > 0 && j > 0 && mappable(x.children[i-1], y.children[j-1]) &&
+				table[i][j] == table[i-1][j-1]+e.cost(x.children[i-1], y.children[j-1]):
+				e.match(x.children[i-1], y.children[j-1])
+				i--
+				j--
+			case i > 0 && table[i][j] == table[i-1][j]+size(x.children[i-1]):
+				i--
+			default:
+				j--
+			}
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package deepdiff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTopDownDiffs(t *testing.T) {
+	cases := []TestCase{
+		{"scalar update",
+			`{"a":1,"b":"x"}`,
+			`{"a":2,"b":"x"}`,
+			Deltas{
+				{Type: DTUpdate, Path: StringAddr("a"), SourceValue: float64(1), Value: float64(2)},
+				{Type: DTContext, Path: StringAddr("b"), Value: "x"},
+			},
+		},
+		{"insert before modified records",
+			`[{"id":1,"n":1},{"id":2,"n":2}]`,
+			`[{"id":0,"n":0},{"id":1,"n":10},{"id":2,"n":20}]`,
+			Deltas{
+				{Type: DTInsert, Path: IndexAddr(0), Value: map[string]interface{}{"id": float64(0), "n": float64(0)}},
+				{Type: DTContext, Path: IndexAddr(1), Deltas: Deltas{
+					{Type: DTContext, Path: StringAddr("id"), Value: float64(1)},
+					{Type: DTUpdate, Path: StringAddr("n"), SourceValue: float64(1), Value: float64(10)},
+				}},
+				{Type: DTContext, Path: IndexAddr(2), Deltas: Deltas{
+					{Type: DTContext, Path: StringAddr("id"), Value: float64(2)},
+					{Type: DTUpdate, Path: StringAddr("n"), SourceValue: float64(2), Value: float64(20)},
+				}},
+			},
+		},
+		{"type change replaces a subtree",
+			`{"a":[1,2],"b":true}`,
+			`{"a":{"c":1},"b":true}`,
+			Deltas{
+				{Type: DTDelete, Path: StringAddr("a"), Value: []interface{}{float64(1), float64(2)}},
+				{Type: DTInsert, Path: StringAddr("a"), Value: map[string]interface{}{"c": float64(1)}},
+				{Type: DTContext, Path: StringAddr("b"), Value: true},
+			},
+		},
+		{"root type change",
+			`[1]`,
+			`{"a":1}`,
+			Deltas{
+				{Type: DTDelete, Path: RootAddr{}, Value: []interface{}{float64(1)}},
+				{Type: DTInsert, Path: RootAddr{}, Value: map[string]interface{}{"a": float64(1)}},
+			},
+		},
+	}
+
+	RunTestCases(t, cases, func(cfg *Config) {
+		cfg.TopDown = true
+		cfg.CalcChanges = true
+	})
+}
+
+func TestTopDownThreshold(t *testing.T) {
+	// heuristic matching leaves the shifted records unmatched, top-down mode
+	// pairs them with their modified counterparts
+	a := []interface{}{"x", []interface{}{"p", "q", "r"}, "y"}
+	b := []interface{}{"y", []interface{}{"p", "q", "s"}, "x"}
+
+	for _, threshold := range []int{0, 100} {
+		ex, _, err := New(func(cfg *Config) { cfg.TopDown = true }).StatDiff(context.Background(), a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := New(func(cfg *Config) { cfg.TopDownThreshold = threshold }).StatDiff(context.Background(), a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if same := cmp.Diff(ex, got) == ""; same != (threshold > 0) {
+			t.Errorf("threshold %d: expected top-down mode: %t, got: %t", threshold, threshold > 0, same)
+		}
+	}
+}
+
+// TestTopDownCrossCheck diffs random documents in top-down & heuristic modes,
+// checking both scripts patch correctly & top-down scripts are never more
+// expensive
+func TestTopDownCrossCheck(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		rnd       = rand.New(rand.NewSource(43))
+		topDown   = New(func(cfg *Config) { cfg.TopDown = true; cfg.CalcChanges = true })
+		heuristic = New(func(cfg *Config) { cfg.CalcChanges = true })
+	)
+
+	for i := 0; i < 500; i++ {
+		a, b := randomDoc(rnd, 3), interface{}(nil)
+		b = mutateDoc(rnd, a, 3)
+		// diffs of scalar roots aren't supported
+		if scalarValue(a) || scalarValue(b) {
+			continue
+		}
+
+		ex, err := topDown.Diff(ctx, a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkPatch(ex, a, b); err != nil {
+			ad, _ := json.Marshal(a)
+			bd, _ := json.Marshal(b)
+			dd, _ := json.Marshal(ex)
+			t.Fatalf("case %d top-down: %s\na: %s\nb: %s\ndeltas: %s", i, err, ad, bd, dd)
+		}
+
+		hr, err := heuristic.Diff(ctx, a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// heuristic scripts aren't guaranteed to patch, only compare costs of
+		// scripts that do
+		if checkPatch(hr, a, b) != nil {
+			continue
+		}
+		if ec, hc := scriptCost(ex), scriptCost(hr); ec > hc {
+			ad, _ := json.Marshal(a)
+			bd, _ := json.Marshal(b)
+			t.Errorf("case %d: top-down script costs %d, heuristic costs %d\na: %s\nb: %s", i, ec, hc, ad, bd)
+		}
+	}
+}
+
+// scalarValue checks if v is neither an array nor an object
+func scalarValue(v interface{}) bool {
+	switch v.(type) {
+	case []interface{}, map[string]interface{}:
+		return false
+	}
+	return true
+}
+
+// checkPatch confirms applying deltas to a copy of a produces b
+func checkPatch(deltas Deltas, a, b interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("patch panicked: %v", r)
+		}
+	}()
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+	if err := Patch(deltas, &result); err != nil {
+		return err
+	}
+	if diff := cmp.Diff(b, result); diff != "" {
+		return fmt.Errorf("patched result mismatch (-want +got):\n%s", diff)
+	}
+	return nil
+}
+
+// scriptCost counts the nodes inserted & deleted by deltas, updates count as
+// deleting the source value & inserting the new one
+func scriptCost(deltas Deltas) (cost int) {
+	for _, dlt := range deltas {
+		switch dlt.Type {
+		case DTInsert, DTDelete:
+			cost += valueSize(dlt.Value)
+		case DTUpdate:
+			cost += valueSize(dlt.SourceValue) + valueSize(dlt.Value)
+		}
+		cost += scriptCost(dlt.Deltas)
+	}
+	return cost
+}
+
+// valueSize counts the nodes in a value
+func valueSize(v interface{}) int {
+	n := 1
+	switch x := v.(type) {
+	case []interface{}:
+		for _, el := range x {
+			n += valueSize(el)
+		}
+	case map[string]interface{}:
+		for _, el := range x {
+			n += valueSize(el)
+		}
+	}
+	return n
+}
+
+// randomDoc generates a random JSON-like document. values are drawn from a
+// small pool so documents share plenty of subtrees
+func randomDoc(rnd *rand.Rand, depth int) interface{} {
+	switch k := rnd.Intn(5); {
+	case depth > 0 && k == 0:
+		arr := make([]interface{}, rnd.Intn(5))
+		for i := range arr {
+			arr[i] = randomDoc(rnd, depth-1)
+		}
+		return arr
+	case depth > 0 && k == 1:
+		obj := map[string]interface{}{}
+		for i := rnd.Intn(5); i > 0; i-- {
+			obj[string(rune('a'+rnd.Intn(6)))] = randomDoc(rnd, depth-1)
+		}
+		return obj
+	case k == 2:
+		return string(rune('p' + rnd.Intn(4)))
+	case k == 3:
+		return rnd.Intn(2) == 0
+	default:
+		return float64(rnd.Intn(4))
+	}
+}
+
+// mutateDoc copies a document with random changes
+func mutateDoc(rnd *rand.Rand, v interface{}, depth int) interface{} {
+	if rnd.Intn(8) == 0 {
+		return randomDoc(rnd, depth)
+	}
+	switch x := v.(type) {
+	case []interface{}:
+		arr := make([]interface{}, 0, len(x)+1)
+		for _, el := range x {
+			switch rnd.Intn(6) {
+			case 0:
+				// drop the element
+			case 1:
+				arr = append(arr, randomDoc(rnd, depth-1), mutateDoc(rnd, el, depth-1))
+			default:
+				arr = append(arr, mutateDoc(rnd, el, depth-1))
+			}
+		}
+		return arr
+	case map[string]interface{}:
+		// visit keys in order so documents are reproducible from a seed
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		obj := map[string]interface{}{}
+		for _, key := range keys {
+			if rnd.Intn(6) > 0 {
+				obj[key] = mutateDoc(rnd, x[key], depth-1)
+			}
+		}
+		if rnd.Intn(3) == 0 {
+			obj[string(rune('a'+rnd.Intn(6)))] = randomDoc(rnd, depth-1)
+		}
+		return obj
+	}
+	return v
+}
@@ -0,0 +1,210 @@
+package deepdiff
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Transform rewrites a value before it's hashed & diffed, normalizing values
+// that should compare as equal. Transforms must not modify the value they're
+// given, returning a new value instead
+type Transform func(v interface{}) interface{}
+
+// TransformRule applies a Transform to every value at a path matching Pattern.
+// Patterns follow the same rules as ArrayModes, "/**" matches every value
+type TransformRule struct {
+	Pattern   string
+	Transform Transform
+}
+
+// transformRule is a TransformRule with a parsed pattern
+type transformRule struct {
+	pattern   pathPattern
+	anyPath   bool
+	transform Transform
+}
+
+func newTransformRules(rules []TransformRule) []transformRule {
+	compiled := make([]transformRule, len(rules))
+	for i, r := range rules {
+		compiled[i] = transformRule{
+			pattern:   parsePathPattern(r.Pattern),
+			anyPath:   r.Pattern == "/**",
+			transform: r.Transform,
+		}
+	}
+	return compiled
+}
+
+// normalize converts v to one of the types diff trees are built from, then
+// runs any configured transforms that apply to the path of v, in order
+func (d *diff) normalize(parent node, addr Addr, v interface{}) interface{} {
+	v = preprocessType(v)
+	if len(d.transforms) == 0 {
+		return v
+	}
+
+	var p []Addr
+	for i, rule := range d.transforms {
+		if !rule.anyPath {
+			if p == nil {
+				p = path(parent)
+				if addr.Value() != nil {
+					p = append(p, addr)
+				}
+			}
+			if !rule.pattern.match(p) {
+				continue
+			}
+		}
+		v = preprocessType(d.transforms[i].transform(v))
+	}
+	return v
+}
+
+// setNormalizedValue sets the value of a node built from transformed values.
+// Nodes keep their raw value if configured, otherwise compound nodes rebuild
+// their value from the normalized values of their children
+func (d *diff) setNormalizedValue(n node, raw interface{}) {
+	switch x := n.(type) {
+	case *scalar:
+		if d.keepRawValues {
+			x.value = raw
+		}
+	case *array:
+		if d.keepRawValues {
+			x.value = raw
+			return
+		}
+		norm := make([]interface{}, len(x.children))
+		for i, ch := range x.children {
+			norm[i] = ch.Value()
+		}
+		x.value = norm
+	case *object:
+		if d.keepRawValues {
+			x.value = raw
+			return
+		}
+		norm := make(map[string]interface{}, len(x.children))
+		for _, ch := range x.children {
+			norm[ch.Addr().String()] = ch.Value()
+		}
+		x.value = norm
+	}
+}
+
+// StringTransform creates a transform that applies fn to strings, leaving other
+// values unchanged. eg: a transform for unicode NFC normalization can be
+// created with StringTransform(norm.NFC.String) from golang.org/x/text
+func StringTransform(fn func(string) string) Transform {
+	return func(v interface{}) interface{} {
+		if str, ok := v.(string); ok {
+			return fn(str)
+		}
+		return v
+	}
+}
+
+// TrimSpace removes leading & trailing whitespace from strings
+var TrimSpace = StringTransform(strings.TrimSpace)
+
+// LowercaseKeys lowercases the keys of objects. When lowercasing makes two
+// keys equal, the value of the key that sorts last is kept
+var LowercaseKeys Transform = func(v interface{}) interface{} {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lower := make(map[string]interface{}, len(obj))
+	for _, key := range keys {
+		lower[strings.ToLower(key)] = obj[key]
+	}
+	return lower
+}
+
+// RoundFloats creates a transform that rounds floating point numbers to a
+// number of decimal places
+func RoundFloats(places int) Transform {
+	scale := math.Pow(10, float64(places))
+	return func(v interface{}) interface{} {
+		if f, ok := v.(float64); ok {
+			return math.Round(f*scale) / scale
+		}
+		return v
+	}
+}
+
+// SortArray sorts the elements of arrays. Elements of different types sort in
+// the order null, bools, numbers, strings, arrays, objects. Arrays & objects
+// are ordered by their formatted value
+var SortArray Transform = func(v interface{}) interface{} {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+	sorted := make([]interface{}, len(arr))
+	for i, el := range arr {
+		sorted[i] = preprocessType(el)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return lessValue(sorted[i], sorted[j]) })
+	return sorted
+}
+
+// typeRank orders values of different types for sorting
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case int64, float64:
+		return 2
+	case string:
+		return 3
+	case []byte:
+		return 4
+	case []interface{}:
+		return 5
+	case map[string]interface{}:
+		return 6
+	}
+	return 7
+}
+
+func lessValue(a, b interface{}) bool {
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		return ra < rb
+	}
+	switch x := a.(type) {
+	case bool:
+		return !x && b.(bool)
+	case int64, float64:
+		return toFloat(a) < toFloat(b)
+	case string:
+		return x < b.(string)
+	case []byte:
+		return bytes.Compare(x, b.([]byte)) < 0
+	}
+	return fmt.Sprintf("%v", a) < fmt.Sprintf("%v", b)
+}
+
+func toFloat(v interface{}) float64 {
+	switch x := v.(type) {
+	case int64:
+		return float64(x)
+	case float64:
+		return x
+	}
+	return 0
+}
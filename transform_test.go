@@ -0,0 +1,98 @@
+package deepdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransforms(t *testing.T) {
+	cases := []TestCase{
+		{
+			"whitespace & rounding",
+			`{"name":"  alice ","score":1.0001}`,
+			`{"name":"alice","score":1.0002}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("name"), Value: "alice"},
+				{Type: DTContext, Path: StringAddr("score"), Value: float64(1)},
+			},
+		},
+		{
+			"lowercased keys",
+			`{"Name":"alice","TAGS":["b","a"]}`,
+			`{"name":"alice","tags":["a","b"]}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("name"), Value: "alice"},
+				{Type: DTContext, Path: StringAddr("tags"), Value: []interface{}{"a", "b"}},
+			},
+		},
+		{
+			"changes are reported normalized",
+			`{"name":"alice ","tags":["c","a"]}`,
+			`{"name":" bob","tags":["a","b"]}`,
+			Deltas{
+				{Type: DTUpdate, Path: StringAddr("name"), SourceValue: "alice", Value: "bob"},
+				{Type: DTContext, Path: StringAddr("tags"), Deltas: Deltas{
+					{Type: DTContext, Path: IndexAddr(0), Value: "a"},
+					{Type: DTUpdate, Path: IndexAddr(1), SourceValue: "c", Value: "b"},
+				}},
+			},
+		},
+	}
+
+	RunDeltaTestCases(t, cases, func(c *Config) {
+		c.CalcChanges = true
+		c.Transforms = []TransformRule{
+			{Pattern: "/**", Transform: TrimSpace},
+			{Pattern: "/", Transform: LowercaseKeys},
+			{Pattern: "/score", Transform: RoundFloats(2)},
+			{Pattern: "/tags", Transform: SortArray},
+		}
+	})
+}
+
+func TestTransformsKeepRawValues(t *testing.T) {
+	cases := []TestCase{
+		{
+			"raw values",
+			`{"name":"alice ","id":1}`,
+			`{"name":" bob","id":1}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("id"), Value: float64(1)},
+				{Type: DTUpdate, Path: StringAddr("name"), SourceValue: "alice ", Value: " bob"},
+			},
+		},
+	}
+
+	RunDeltaTestCases(t, cases, func(c *Config) {
+		c.CalcChanges = true
+		c.KeepRawValues = true
+		c.Transforms = []TransformRule{{Pattern: "/name", Transform: TrimSpace}}
+	})
+}
+
+func TestSortArray(t *testing.T) {
+	got := SortArray([]interface{}{"b", float64(2), nil, map[string]interface{}{}, true, int64(1), []interface{}{}, false, "a"})
+	expect := []interface{}{nil, false, true, int64(1), float64(2), "a", "b", []interface{}{}, map[string]interface{}{}}
+	if !reflect.DeepEqual(expect, got) {
+		t.Errorf("result mismatch.\nwant: %#v\ngot:  %#v", expect, got)
+	}
+}
+
+func TestPreprocessIntegers(t *testing.T) {
+	cases := []struct {
+		in, expect interface{}
+	}{
+		{int(1), int64(1)},
+		{int8(-2), int64(-2)},
+		{uint8(3), int64(3)},
+		{uint32(4), int64(4)},
+		{uint64(5), int64(5)},
+		{uint64(1 << 63), float64(1 << 63)},
+		{float32(0.5), float64(0.5)},
+	}
+	for _, c := range cases {
+		if got := preprocessType(c.in); got != c.expect {
+			t.Errorf("%T(%v): expected %#v, got %#v", c.in, c.in, c.expect, got)
+		}
+	}
+}
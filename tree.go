@@ -2,7 +2,10 @@ package deepdiff
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
+	"hash"
+	"math"
 	"sort"
 	"strconv"
 	"sync"
@@ -21,9 +24,12 @@ const (
 	ntInt
 	ntBool
 	ntNull
+	ntBytes
 )
 
-// node represents a value in a tree for diff computation
+// node represents a value in a tree for diff computation. nodes are immutable
+// once built, state that changes while calculating a diff (matches, change
+// types & shifted addresses) is kept by the diff, keyed by node index
 type node interface {
 	Type() nodeType
 	// a byte hash of this node's content & any child nodes
@@ -35,27 +41,18 @@ type node interface {
 	// the name this parent has given this node. for arrays this'll be the string
 	// value of this node's index, for objects this will be the key
 	Addr() Addr
-	// assign this node's name, only needs to be used when re-ordering nodes
-	// post-deletion & insertion
-	SetAddr(Addr)
 	// the actual data this node is created from
 	Value() interface{}
-	// this node's counterpart in another node tree
-	Match() node
-	// assign this node's counterpart
-	SetMatch(node)
-
-	// node modification type accessor
-	ChangeType() (o Operation)
-	// assign a modification type to this node
-	SetChangeType(Operation)
+	// position of this node in diff state
+	index() int
+	setIndex(int)
 }
 
 // compound represents a data type that can contain children
 // basically objects & arrays
 type compound interface {
 	node
-	// list children, for objects this will come in random order
+	// list children. object children are sorted by key, array children by index
 	Children() []node
 	// get a child by address
 	Child(address Addr) node
@@ -69,53 +66,56 @@ type compound interface {
 	DropChildNodes()
 }
 
-// nodes implements the sort interface for a slice of nodes
-type nodes []node
-
-func (ns nodes) Len() int           { return len(ns) }
-func (ns nodes) Less(i, j int) bool { return ns[i].Addr().String() < ns[j].Addr().String() }
-func (ns nodes) Swap(i, j int)      { ns[i], ns[j] = ns[j], ns[i] }
-
 type object struct {
 	addr   Addr
 	hash   []byte
 	parent node
 	weight int
 	value  interface{}
-	match  node
-	change Operation
+	idx    int
 
 	descendants int
-	children    map[Addr]node
+	// children sorted by address
+	children []node
 }
 
 func (o object) Type() nodeType              { return ntObject }
 func (o object) Addr() Addr                { return o.addr }
-func (o *object) SetAddr(addr Addr)        { o.addr = addr }
 func (o object) Hash() []byte                { return o.hash }
 func (o object) Weight() int                 { return o.weight }
 func (o object) Parent() node                { return o.parent }
-func (o object) Value() interface{}          { return o.value }
-func (o object) Match() node                 { return o.match }
-func (o *object) SetMatch(n node)            { o.match = n }
-func (o *object) SetChangeType(dt Operation) { o.change = dt }
-func (o *object) ChangeType() Operation      { return o.change }
-func (o object) Children() []node {
-	nodes := make([]node, len(o.children))
-	i := 0
-	for _, ch := range o.children {
-		nodes[i] = ch
-		i++
-	}
-	return nodes
+func (o object) Value() interface{}          { return materialize(o.value) }
+func (o object) index() int                  { return o.idx }
+func (o *object) setIndex(i int)             { o.idx = i }
+func (o object) Children() []node            { return o.children }
+func (o object) Child(a Addr) node {
+	if i := o.search(a.String()); i < len(o.children) && o.children[i].Addr().String() == a.String() {
+		return o.children[i]
+	}
+	return nil
 }
-func (o object) Child(a Addr) node { return o.children[a] }
+
+// search finds the position of key in the sorted list of children
+func (o object) search(key string) int {
+	return sort.Search(len(o.children), func(i int) bool { return o.children[i].Addr().String() >= key })
+}
+
+// AddChild adds n to the sorted list of children, after any child with the
+// same address. Child keeps returning the existing child, which happens when a
+// deleted value is folded into a tree that replaced it
 func (o *object) AddChild(n node) {
 	if cmp, ok := n.(compound); ok {
 		o.descendants += cmp.DescendantsCount()
 	}
 	o.descendants++
-	o.children[n.Addr()] = n
+
+	key := n.Addr().String()
+	i := sort.Search(len(o.children), func(i int) bool { return o.children[i].Addr().String() > key })
+	children := make([]node, len(o.children)+1)
+	copy(children, o.children[:i])
+	children[i] = n
+	copy(children[i+1:], o.children[i:])
+	o.children = children
 }
 func (o *object) DropChildNodes()      { o.children = nil }
 func (o object) DescendantsCount() int { return o.descendants }
@@ -126,29 +126,25 @@ type array struct {
 	parent node
 	weight int
 	value  interface{}
-	match  node
-	change Operation
+	idx    int
 
 	descendants int
-	childNames  map[Addr]int
 	children    []node
+	mode        ArrayMode
 }
 
 func (c array) Type() nodeType              { return ntArray }
 func (c array) Addr() Addr                { return c.addr }
-func (c *array) SetAddr(addr Addr)        { c.addr = addr }
 func (c array) Hash() []byte                { return c.hash }
 func (c array) Weight() int                 { return c.weight }
 func (c array) Parent() node                { return c.parent }
-func (c array) Value() interface{}          { return c.value }
-func (c array) Match() node                 { return c.match }
-func (c *array) SetMatch(n node)            { c.match = n }
-func (c *array) ChangeType() Operation      { return c.change }
-func (c *array) SetChangeType(dt Operation) { c.change = dt }
+func (c array) Value() interface{}          { return materialize(c.value) }
+func (c array) index() int                  { return c.idx }
+func (c *array) setIndex(i int)             { c.idx = i }
 func (c array) Children() []node            { return c.children }
 func (c array) Child(addr Addr) node {
-	if c.childNames[addr] < len(c.children) {
-		return c.children[c.childNames[addr]]
+	if i, ok := addr.Value().(int); ok && i >= 0 && i < len(c.children) {
+		return c.children[i]
 	}
 	return nil
 }
@@ -169,109 +165,232 @@ type scalar struct {
 	parent node
 	value  interface{}
 	weight int
-	match  node
-	change Operation
+	idx    int
+
+	// comparison key set by a Comparator
+	key   string
+	keyed bool
 }
 
 func (s scalar) Type() nodeType              { return s.t }
 func (s scalar) Addr() Addr                { return s.addr }
-func (s *scalar) SetAddr(addr Addr)        { s.addr = addr }
 func (s scalar) Hash() []byte                { return s.hash }
 func (s scalar) Weight() int                 { return s.weight }
 func (s scalar) Parent() node                { return s.parent }
 func (s scalar) Value() interface{}          { return s.value }
-func (s scalar) Match() node                 { return s.match }
-func (s *scalar) SetMatch(n node)            { s.match = n }
-func (s *scalar) ChangeType() Operation      { return s.change }
-func (s *scalar) SetChangeType(dt Operation) { s.change = dt }
+func (s scalar) index() int                  { return s.idx }
+func (s *scalar) setIndex(i int)             { s.idx = i }
 
+// prepTrees builds trees for both sides of the diff, preparing t1 unless the
+// diff has a prepared left side, and allocates diff state for all nodes
 func (d *diff) prepTrees(ctx context.Context) (t1, t2 node, t1nodes map[string][]node) {
+	var done chan struct{}
+	if d.prepared == nil {
+		done = make(chan struct{})
+		go func() {
+			d.prepared = d.prepare(d.d1)
+			close(done)
+		}()
+	}
+
+	var t2nodes []node
+	if d.right != nil {
+		t2, t2nodes = d.right.root, d.right.nodes
+	} else {
+		t2, t2nodes = d.buildTree(d.d2)
+	}
+	if done != nil {
+		<-done
+	}
+
+	p := d.prepared
+	t2Weight := 0
+	for i, n := range t2nodes {
+		n.setIndex(p.count + i)
+		t2Weight += n.Weight()
+	}
+	d.initState(p.count + len(t2nodes))
+
+	if d.stats != nil {
+		d.stats.Left = p.count
+		d.stats.LeftWeight = p.weight
+		d.stats.Right = len(t2nodes)
+		d.stats.RightWeight = t2Weight
+	}
+	return p.tree, t2, p.nodes
+}
+
+// builtTree is a tree built ahead of diffing
+type builtTree struct {
+	root  node
+	nodes []node
+	// the document a tree built from JSON holds values of
+	json *jsonSource
+}
+
+// buildTree builds a tree from v, returning the root node & a list of every
+// node in the tree
+func (d *diff) buildTree(v interface{}) (root node, nodes []node) {
+	if d.workers > 1 {
+		d.sem = make(chan struct{}, d.workers)
+	}
+	a := newArena(d.newHash)
+	root = d.tree(v, RootAddr{}, nil, a)
+	return root, a.nodes
+}
+
+// buildChildren builds count child nodes with build. Arrays & objects with at
+// least parallelThreshold children are split into chunks built in parallel,
+// bounded by the number of workers. Each chunk is built with its own arena,
+// nodes from each chunk are listed in order, matching a sequential build
+func (d *diff) buildChildren(count int, a *arena, build func(i int, a *arena) node) []node {
+	children := a.childList(count)
+	if d.sem == nil || count < d.parallelThreshold {
+		for i := range children {
+			children[i] = build(i, a)
+		}
+		return children
+	}
+
 	var (
-		wg                sync.WaitGroup
-		t1nodesCh         = make(chan node)
-		t2nodesCh         = make(chan node)
-		t1Count, t1Weight int
-		t2Count, t2Weight int
+		wg     sync.WaitGroup
+		size   = (count + d.workers - 1) / d.workers
+		arenas = make([]*arena, (count+size-1)/size)
 	)
+	buildChunk := func(c int) {
+		arenas[c] = newArena(d.newHash)
+		stop := (c + 1) * size
+		if stop > count {
+			stop = count
+		}
+		for i := c * size; i < stop; i++ {
+			children[i] = build(i, arenas[c])
+		}
+	}
 
-	t1nodes = map[string][]node{}
-	wg.Add(2)
+	for c := range arenas {
+		select {
+		case d.sem <- struct{}{}:
+			wg.Add(1)
+			go func(c int) {
+				buildChunk(c)
+				<-d.sem
+				wg.Done()
+			}(c)
+		default:
+			// all workers are busy, build this chunk on the current goroutine
+			buildChunk(c)
+		}
+	}
+	wg.Wait()
+
+	for _, chunk := range arenas {
+		a.nodes = append(a.nodes, chunk.nodes...)
+	}
+	return children
+}
+
+// tree builds a node from v in arena a, which lists the node & all its
+// descendants in bottom-up order
+func (d *diff) tree(v interface{}, addr Addr, parent node, a *arena) (n node) {
+	raw := v
+	v = d.normalize(parent, addr, v)
+	switch x := v.(type) {
+	case []interface{}:
+		arr := a.array()
+		*arr = array{
+			addr:   addr,
+			parent: parent,
+			value:  v,
+			mode:   d.arrayMode(parent, addr),
+		}
 
-	go func(nodes <-chan node) {
-		for n := range nodes {
-			key := hashStr(n.Hash())
-			t1nodes[key] = append(t1nodes[key], n)
-			t1Count++
-			t1Weight += n.Weight()
+		arr.children = d.buildChildren(len(x), a, func(i int, a *arena) node {
+			v := x[i]
+			// arrays can't have missing elements, values treated as missing are
+			// compared as null
+			if d.absent(v) {
+				v = nil
+			}
+			return d.tree(v, IndexAddr(i), arr, a)
+		})
+		d.sealArray(arr, a)
+		n = arr
+	case map[string]interface{}:
+		obj := a.object()
+		*obj = object{
+			addr:   addr,
+			parent: parent,
+			value:  v,
 		}
-		wg.Done()
-	}(t1nodesCh)
-	go func() {
-		t1 = tree(d.d1, RootAddr{}, nil, t1nodesCh)
-		close(t1nodesCh)
-	}()
-
-	go func(nodes <-chan node) {
-		for n := range nodes {
-			// do nothing
-			t2Count++
-			t2Weight += n.Weight()
+
+		// gotta sort keys for consistent hashing :(
+		keys := make([]string, 0, len(x))
+		for name, val := range x {
+			// keys with values treated as missing are skipped entirely
+			if d.absent(val) {
+				continue
+			}
+			keys = append(keys, name)
 		}
-		wg.Done()
-	}(t2nodesCh)
-	go func() {
-		t2 = tree(d.d2, RootAddr{}, nil, t2nodesCh)
-		close(t2nodesCh)
-	}()
+		sort.Strings(keys)
 
-	wg.Wait()
+		obj.children = d.buildChildren(len(keys), a, func(i int, a *arena) node {
+			return d.tree(x[keys[i]], StringAddr(keys[i]), obj, a)
+		})
+		d.sealObject(obj, a)
+		n = obj
+	default:
+		n = d.scalar(v, addr, parent, a)
+	}
 
-	if d.stats != nil {
-		d.stats.Left = t1Count
-		d.stats.LeftWeight = t1Weight
-		d.stats.Right = t2Count
-		d.stats.RightWeight = t2Weight
+	if len(d.transforms) > 0 {
+		d.setNormalizedValue(n, raw)
 	}
+
+	a.add(n)
 	return
 }
 
-func tree(v interface{}, addr Addr, parent node, nodes chan node) (n node) {
-	v = preprocessType(v)
+// scalar creates a leaf node from a scalar value, applying any configured
+// comparator
+func (d *diff) scalar(v interface{}, addr Addr, parent node, a *arena) node {
+	n := a.scalar()
 	switch x := v.(type) {
 	case nil:
-		n = &scalar{
+		*n = scalar{
 			t:      ntNull,
 			addr:   addr,
-			hash:   NewHash().Sum([]byte("null")),
+			hash:   hashScalar(a, ntNull, "null"),
 			parent: parent,
 			value:  v,
 			weight: 1,
 		}
 	case int64:
 		istr := strconv.FormatInt(x, 10)
-		n = &scalar{
+		*n = scalar{
 			t:      ntInt,
 			addr:   addr,
-			hash:   NewHash().Sum([]byte(istr)),
+			hash:   hashScalar(a, ntInt, istr),
 			parent: parent,
 			value:  v,
 			weight: len(istr),
 		}
 	case float64:
 		fstr := strconv.FormatFloat(x, 'f', -1, 64)
-		n = &scalar{
+		*n = scalar{
 			t:      ntFloat,
 			addr:   addr,
-			hash:   NewHash().Sum([]byte(fstr)),
+			hash:   hashScalar(a, ntFloat, fstr),
 			parent: parent,
 			value:  v,
 			weight: len(fstr),
 		}
 	case string:
-		n = &scalar{
+		*n = scalar{
 			t:      ntString,
 			addr:   addr,
-			hash:   NewHash().Sum([]byte(x)),
+			hash:   hashScalar(a, ntString, x),
 			parent: parent,
 			value:  v,
 			weight: len(x),
@@ -281,83 +400,120 @@ func tree(v interface{}, addr Addr, parent node, nodes chan node) (n node) {
 		if x {
 			bstr = "true"
 		}
-		n = &scalar{
+		*n = scalar{
 			t:      ntBool,
 			addr:   addr,
-			hash:   NewHash().Sum([]byte(bstr)),
+			hash:   hashScalar(a, ntBool, bstr),
 			parent: parent,
 			value:  v,
 			weight: len(bstr),
 		}
-	case []interface{}:
-		hasher := NewHash()
-		arr := &array{
-			addr:       addr,
-			parent:     parent,
-			childNames: map[Addr]int{},
-			children:   make([]node, len(x)),
-			value:      v,
+	case []byte:
+		*n = scalar{
+			t:      ntBytes,
+			addr:   addr,
+			hash:   hashScalar(a, ntBytes, string(x)),
+			parent: parent,
+			value:  v,
+			weight: len(x),
 		}
+	default:
+		panic(fmt.Sprintf("unexpected type: %T", v))
+	}
 
-		for i, v := range x {
-			node := tree(v, IndexAddr(i), arr, nodes)
-			hasher.Write(node.Hash())
-			arr.childNames[IndexAddr(i)] = i
-			arr.children[i] = node
+	if len(d.comparatorPatterns) > 0 {
+		d.applyComparator(n, a)
+	}
+	return n
+}
 
-			if cmp, ok := node.(compound); ok {
-				arr.descendants += cmp.DescendantsCount()
-			}
-			arr.descendants++
+// sealArray calculates the hash, weight & descendant count of an array from
+// its children
+func (d *diff) sealArray(arr *array, a *arena) {
+	arr.weight = 1
+	for _, node := range arr.children {
+		if cmp, ok := node.(compound); ok {
+			arr.descendants += cmp.DescendantsCount()
 		}
-		arr.hash = hasher.Sum(nil)
+		arr.descendants++
+		arr.weight += node.Weight()
+	}
 
-		arr.weight = 1
-		for _, ch := range arr.children {
-			arr.weight += ch.Weight()
-		}
-		n = arr
-	case map[string]interface{}:
-		hasher := NewHash()
-		obj := &object{
-			addr:     addr,
-			parent:   parent,
-			children: map[Addr]node{},
-			value:    v,
-		}
+	if arr.mode != ArrayOrdered {
+		arr.hash = unorderedHash(a, arr.children, arr.mode)
+		return
+	}
+	hasher := a.hash()
+	hasher.Write([]byte{byte(ntArray)})
+	for _, node := range arr.children {
+		hasher.Write(node.Hash())
+	}
+	arr.hash = a.sum(hasher)
+}
 
-		// gotta sort keys for consistent hashing :(
-		addrs := make(sortableAddrs, 0, len(x))
-		for name := range x {
-			addrs = append(addrs, StringAddr(name))
+// sealObject calculates the hash, weight & descendant count of an object from
+// its children, which must be sorted by address
+func (d *diff) sealObject(obj *object, a *arena) {
+	hasher := a.hash()
+	hasher.Write([]byte{byte(ntObject)})
+	obj.weight = 1
+	for _, node := range obj.children {
+		writeHashKey(a, hasher, node.Addr().String())
+		hasher.Write(node.Hash())
+		if cmp, ok := node.(compound); ok {
+			obj.descendants += cmp.DescendantsCount()
 		}
-		sort.Sort(addrs)
+		obj.descendants++
+		obj.weight += node.Weight()
+	}
+	obj.hash = a.sum(hasher)
+}
 
-		for _, addr := range addrs {
-			node := tree(x[addr.String()], addr, obj, nodes)
-			hasher.Write(node.Hash())
-			obj.children[addr] = node
+// absent returns true if v is configured to be treated as a missing value
+func (d *diff) absent(v interface{}) bool {
+	if !d.nullAsMissing && !d.emptyArrayAsMissing && !d.emptyObjectAsMissing {
+		return false
+	}
 
-			if cmp, ok := node.(compound); ok {
-				obj.descendants += cmp.DescendantsCount()
-			}
-			obj.descendants++
+	switch x := preprocessType(v).(type) {
+	case nil:
+		return d.nullAsMissing
+	case []interface{}:
+		return d.emptyArrayAsMissing && len(x) == 0
+	case map[string]interface{}:
+		if !d.emptyObjectAsMissing {
+			return false
 		}
-		obj.hash = hasher.Sum(nil)
-
-		obj.weight = 1
-		for _, ch := range obj.children {
-			obj.weight += ch.Weight()
+		// objects that only hold missing values are empty
+		for _, val := range x {
+			if !d.absent(val) {
+				return false
+			}
 		}
-		n = obj
-	default:
-		panic(fmt.Sprintf("unexpected type: %T", v))
+		return true
 	}
+	return false
+}
 
-	nodes <- n
-	return
+// hashScalar hashes the string form of a scalar value, tagged with its type
+// so values of different types that format the same never collide
+func hashScalar(a *arena, t nodeType, str string) []byte {
+	hasher := a.hash()
+	hasher.Write([]byte{byte(t)})
+	a.writeString(hasher, str)
+	return a.sum(hasher)
+}
+
+// writeHashKey writes a length-prefixed object key to a hash, keeping the
+// boundary between a key and the child hash that follows it unambiguous
+func writeHashKey(a *arena, hasher hash.Hash, key string) {
+	var buf [binary.MaxVarintLen64]byte
+	hasher.Write(buf[:binary.PutUvarint(buf[:], uint64(len(key)))])
+	a.writeString(hasher, key)
 }
 
+// preprocessType converts common go types to the types diff trees are built
+// from. It's the first step in normalizing any value
 func preprocessType(v interface{}) interface{} {
 	switch x := v.(type) {
 	case map[interface{}]interface{}:
@@ -372,12 +528,24 @@ func preprocessType(v interface{}) interface{} {
 			conv[i] = s
 		}
 		return conv
+	case int:
+		return int64(x)
+	case int8:
+		return int64(x)
+	case int16:
+		return int64(x)
+	case int32:
+		return int64(x)
+	case uint:
+		return uint64ToNumber(uint64(x))
 	case uint8:
-		return int(x)
+		return int64(x)
 	case uint16:
-		return int(x)
+		return int64(x)
 	case uint32:
-		return int(x)
+		return int64(x)
+	case uint64:
+		return uint64ToNumber(x)
 	case float32:
 		return float64(x)
 	default:
@@ -385,6 +553,15 @@ func preprocessType(v interface{}) interface{} {
 	}
 }
 
+// uint64ToNumber converts to int64, falling back to float64 for values that
+// overflow int64
+func uint64ToNumber(x uint64) interface{} {
+	if x > math.MaxInt64 {
+		return float64(x)
+	}
+	return int64(x)
+}
+
 // path computes the string path from
 func path(n node) []Addr {
 	var path []Addr
@@ -398,8 +575,15 @@ func path(n node) []Addr {
 	return path
 }
 
-// walk a tree in top-down (prefix) order
+// walkDepth is the depth of paths allocated up front when walking a tree
+const walkDepth = 16
+
+// walk a tree in top-down (prefix) order. paths share backing arrays between
+// siblings, so fn can't keep a path after it returns
 func walk(tree node, path []Addr, fn func(path []Addr, n node) bool) {
+	if path == nil {
+		path = make([]Addr, 0, walkDepth)
+	}
 	if !tree.Addr().Eq(RootAddr{}) {
 		path = append(path, tree.Addr())
 	}
@@ -411,26 +595,11 @@ func walk(tree node, path []Addr, fn func(path []Addr, n node) bool) {
 	}
 }
 
-// walk a tree in top-down (prefix) order, sorting array keys before recursing.
-// more expensive
-func walkSorted(tree node, path []Addr, fn func(path []Addr, n node) bool) {
-	if !tree.Addr().Eq(RootAddr{}) {
-		path = append(path, tree.Addr())
-	}
-
-	kontinue := fn(path, tree)
-	if cmp, ok := tree.(compound); kontinue && ok {
-		children := nodes(cmp.Children())
-		sort.Sort(children)
-		for _, n := range children {
-			walkSorted(n, path, fn)
-		}
-	}
-}
-
-
 // walk a tree in bottom up (postfix) order
 func walkPostfix(tree node, path []Addr, fn func(path []Addr, n node)) {
+	if path == nil {
+		path = make([]Addr, 0, walkDepth)
+	}
 	if !tree.Addr().Eq(RootAddr{}) {
 		path = append(path, tree.Addr())
 	}
@@ -441,30 +610,3 @@ func walkPostfix(tree node, path []Addr, fn func(path []Addr, n node)) {
 	}
 	fn(path, tree)
 }
-
-func nodeAtPath(tree node, path []Addr) (n node) {
-	n = tree
-	for _, addr := range path {
-		if cmp, ok := n.(compound); ok {
-			n = cmp.Child(addr)
-			if n == nil {
-				return nil
-			}
-		}
-	}
-	return
-}
-
-func addNode(tree, toAdd node, paths []Addr) {
-	if cmp, ok := tree.(compound); ok && len(paths) > 0 {
-		for _, addr := range paths[:len(paths)-1] {
-			tree = cmp.Child(addr)
-			if tree == nil {
-				return
-			}
-		}
-	}
-	if cmp, ok := tree.(compound); ok {
-		cmp.AddChild(toAdd)
-	}
-}
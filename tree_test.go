@@ -0,0 +1,136 @@
+package deepdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestHashCollisions(t *testing.T) {
+	distinct := [][2]interface{}{
+		{"true", true},
+		{"false", false},
+		{"1", int64(1)},
+		{"1", float64(1)},
+		{int64(1), float64(1)},
+		{"null", nil},
+		{"", nil},
+		{"[]", []interface{}{}},
+		{"{}", map[string]interface{}{}},
+		{[]interface{}{}, map[string]interface{}{}},
+		{[]interface{}{}, nil},
+		{[]interface{}{float64(1)}, float64(1)},
+		{[]interface{}{float64(1)}, map[string]interface{}{"a": float64(1)}},
+		{map[string]interface{}{"a": float64(1)}, map[string]interface{}{"b": float64(1)}},
+		{map[string]interface{}{"a": "b"}, map[string]interface{}{"b": "a"}},
+		{map[string]interface{}{"ab": "c"}, map[string]interface{}{"a": "bc"}},
+		{map[string]interface{}{"a": "", "b": ""}, map[string]interface{}{"ab": ""}},
+		{[]interface{}{[]interface{}{}}, []interface{}{}},
+		{[]interface{}{"a", "b"}, []interface{}{"b", "a"}},
+		{[]interface{}{"ab"}, []interface{}{"a", "b"}},
+	}
+
+	d := New().newDiff(nil, nil)
+	for _, c := range distinct {
+		a := d.tree(c[0], RootAddr{}, nil, newArena(d.newHash))
+		b := d.tree(c[1], RootAddr{}, nil, newArena(d.newHash))
+		if bytes.Equal(a.Hash(), b.Hash()) {
+			aj, _ := json.Marshal(c[0])
+			bj, _ := json.Marshal(c[1])
+			t.Errorf("expected %s (%T) and %s (%T) to hash differently", aj, c[0], bj, c[1])
+		}
+	}
+
+	equal := [][2]string{
+		{`{"a":1,"b":[1,2]}`, `{"b":[1,2],"a":1}`},
+		{`[{"a":null}]`, `[{"a":null}]`},
+	}
+	for _, c := range equal {
+		var av, bv interface{}
+		if err := json.Unmarshal([]byte(c[0]), &av); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(c[1]), &bv); err != nil {
+			t.Fatal(err)
+		}
+		a := d.tree(av, RootAddr{}, nil, newArena(d.newHash))
+		b := d.tree(bv, RootAddr{}, nil, newArena(d.newHash))
+		if !bytes.Equal(a.Hash(), b.Hash()) {
+			t.Errorf("expected %s and %s to hash equally", c[0], c[1])
+		}
+	}
+}
+
+func TestTypeCollisionDiffs(t *testing.T) {
+	cases := []TestCase{
+		{
+			"string & bool",
+			`{"a":"true","b":1}`,
+			`{"a":true,"b":1}`,
+			Deltas{
+				{Type: DTDelete, Path: StringAddr("a"), Value: "true"},
+				{Type: DTInsert, Path: StringAddr("a"), Value: true},
+				{Type: DTContext, Path: StringAddr("b"), Value: float64(1)},
+			},
+		},
+		{
+			"string & null",
+			`["null",2]`,
+			`[null,2]`,
+			Deltas{
+				{Type: DTDelete, Path: IndexAddr(0), Value: "null"},
+				{Type: DTInsert, Path: IndexAddr(0), Value: nil},
+				{Type: DTContext, Path: IndexAddr(1), Value: float64(2)},
+			},
+		},
+		{
+			"renamed key",
+			`{"a":[1],"c":2}`,
+			`{"b":[1],"c":2}`,
+			Deltas{
+				{Type: DTDelete, Path: StringAddr("a"), Value: []interface{}{float64(1)}},
+				{Type: DTInsert, Path: StringAddr("b"), Value: []interface{}{float64(1)}},
+				{Type: DTContext, Path: StringAddr("c"), Value: float64(2)},
+			},
+		},
+		{
+			"value moved to another parent",
+			`{"a":{"b":[true,false]},"c":"d"}`,
+			`{"a":{"b":[true,false]},"c":"e","f":false}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("a"), Value: map[string]interface{}{"b": []interface{}{true, false}}},
+				{Type: DTDelete, Path: StringAddr("c"), Value: "d"},
+				{Type: DTInsert, Path: StringAddr("c"), Value: "e"},
+				{Type: DTInsert, Path: StringAddr("f"), Value: false},
+			},
+		},
+	}
+
+	RunTestCases(t, cases)
+}
+
+func TestParallelTree(t *testing.T) {
+	doc := map[string]interface{}{
+		"rows": makeRows(500, 3),
+		"meta": map[string]interface{}{"a": []interface{}{1, 2, 3}},
+	}
+
+	seq, seqNodes := New(func(c *Config) { c.Workers = 1 }).newDiff(nil, nil).buildTree(doc)
+	par, parNodes := New(func(c *Config) {
+		c.Workers = 4
+		c.ParallelThreshold = 2
+	}).newDiff(nil, nil).buildTree(doc)
+
+	if !bytes.Equal(seq.Hash(), par.Hash()) {
+		t.Errorf("root hash mismatch")
+	}
+	if len(seqNodes) != len(parNodes) {
+		t.Fatalf("node count mismatch. sequential: %d, parallel: %d", len(seqNodes), len(parNodes))
+	}
+	for i, n := range seqNodes {
+		if !bytes.Equal(n.Hash(), parNodes[i].Hash()) {
+			t.Errorf("node %d: order mismatch. expected %s, got %s", i, pathString(path(n)), pathString(path(parNodes[i])))
+			break
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package deepdiff
+
+import (
+	"bytes"
+	"sort"
+)
+
+// ArrayMode determines how the elements of an array are compared
+type ArrayMode uint8
+
+const (
+	// ArrayOrdered compares arrays element by element, the default
+	ArrayOrdered ArrayMode = iota
+	// ArraySet compares arrays as sets. Element order & repeated elements are
+	// ignored
+	ArraySet
+	// ArrayMultiset compares arrays as multisets. Element order is ignored, but
+	// the number of times an element repeats is significant
+	ArrayMultiset
+)
+
+// arrayMode returns the configured mode for an array at addr within parent
+func (d *diff) arrayMode(parent node, addr Addr) ArrayMode {
+	if len(d.arrayPatterns) == 0 {
+		return ArrayOrdered
+	}
+	p := path(parent)
+	if addr.Value() != nil {
+		p = append(p, addr)
+	}
+	if pattern, ok := d.arrayPatterns.match(p); ok {
+		return d.arrayModes[pattern]
+	}
+	return ArrayOrdered
+}
+
+// unorderedHash calculates an order-independent hash of child nodes by
+// hashing the sorted list of child hashes. Sets drop repeated hashes
+func unorderedHash(a *arena, children []node, mode ArrayMode) []byte {
+	sums := make([][]byte, len(children))
+	for i, ch := range children {
+		sums[i] = ch.Hash()
+	}
+	sort.Slice(sums, func(i, j int) bool { return bytes.Compare(sums[i], sums[j]) < 0 })
+
+	hasher := a.hash()
+	hasher.Write([]byte{byte(ntArray), byte(mode)})
+	for i, sum := range sums {
+		if mode == ArraySet && i > 0 && bytes.Equal(sum, sums[i-1]) {
+			continue
+		}
+		hasher.Write(sum)
+	}
+	return a.sum(hasher)
+}
+
+// unordered returns true if a node is an array that ignores element order
+func unordered(n node) bool {
+	arr, ok := n.(*array)
+	return ok && arr.mode != ArrayOrdered
+}
+
+// matchUnorderedChildren pairs the children of two matched arrays by hash,
+// ignoring position. Any existing matches of children are replaced. In set
+// mode, repeated elements are matched to an equal element in the other array
+// without being paired, so repetition never produces a change
+func (d *diff) matchUnorderedChildren(a, b *array) {
+	mode := a.mode
+	if mode == ArrayOrdered {
+		mode = b.mode
+	}
+
+	for _, ch := range a.Children() {
+		d.unmatch(ch)
+	}
+	for _, ch := range b.Children() {
+		d.unmatch(ch)
+	}
+
+	byHash := map[string][]node{}
+	for _, ch := range a.Children() {
+		key := hashStr(ch.Hash())
+		byHash[key] = append(byHash[key], ch)
+	}
+
+	bByHash := map[string]node{}
+	for _, bch := range b.Children() {
+		key := hashStr(bch.Hash())
+		if candidates := byHash[key]; len(candidates) > 0 {
+			d.setMatch(candidates[0], bch)
+			d.setMatch(bch, candidates[0])
+			byHash[key] = candidates[1:]
+			bByHash[key] = bch
+		} else if prev := bByHash[key]; mode == ArraySet && prev != nil {
+			d.setMatch(bch, d.match(prev))
+		}
+	}
+
+	if mode == ArraySet {
+		for key, remaining := range byHash {
+			if bch := bByHash[key]; bch != nil {
+				for _, ach := range remaining {
+					d.setMatch(ach, bch)
+				}
+			}
+		}
+	}
+}
+
+// unmatch removes any match n has, along with its counterpart's match to n
+func (d *diff) unmatch(n node) {
+	if m := d.match(n); m != nil {
+		if d.match(m) == n {
+			d.setMatch(m, nil)
+		}
+		d.setMatch(n, nil)
+	}
+}
@@ -0,0 +1,201 @@
+package deepdiff
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnorderedArrays(t *testing.T) {
+	cases := []struct {
+		description string
+		mode        ArrayMode
+		src, dst    string
+		expect      Deltas
+	}{
+		{"set reorder is not a change", ArraySet,
+			`{"tags":["a","b","c"],"n":1}`,
+			`{"tags":["c","b","a"],"n":1}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("n"), Value: float64(1)},
+				{Type: DTContext, Path: StringAddr("tags"), Value: []interface{}{"c", "b", "a"}},
+			},
+		},
+		{"set add & remove members", ArraySet,
+			`{"tags":["a","b","c"],"n":1}`,
+			`{"tags":["c","a","d"],"n":1}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("n"), Value: float64(1)},
+				{Type: DTContext, Path: StringAddr("tags"), Deltas: Deltas{
+					{Type: DTContext, Path: IndexAddr(0), Value: "c"},
+					{Type: DTDelete, Path: IndexAddr(1), Value: "b"},
+					{Type: DTContext, Path: IndexAddr(1), Value: "a"},
+					{Type: DTInsert, Path: IndexAddr(2), Value: "d"},
+				}},
+			},
+		},
+		{"set ignores repeated members", ArraySet,
+			`{"tags":["a","b"],"n":1}`,
+			`{"tags":["b","a","a"],"n":1}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("n"), Value: float64(1)},
+				{Type: DTContext, Path: StringAddr("tags"), Value: []interface{}{"b", "a", "a"}},
+			},
+		},
+		{"multiset counts repeated members", ArrayMultiset,
+			`{"tags":["a","b"],"n":1}`,
+			`{"tags":["b","a","a"],"n":1}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("n"), Value: float64(1)},
+				{Type: DTContext, Path: StringAddr("tags"), Deltas: Deltas{
+					{Type: DTContext, Path: IndexAddr(0), Value: "b"},
+					{Type: DTContext, Path: IndexAddr(1), Value: "a"},
+					{Type: DTInsert, Path: IndexAddr(2), Value: "a"},
+				}},
+			},
+		},
+		{"multiset removes a repeated member", ArrayMultiset,
+			`{"tags":["a","a","b"],"n":1}`,
+			`{"tags":["b","a"],"n":1}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("n"), Value: float64(1)},
+				{Type: DTContext, Path: StringAddr("tags"), Deltas: Deltas{
+					{Type: DTContext, Path: IndexAddr(0), Value: "b"},
+					{Type: DTDelete, Path: IndexAddr(1), Value: "a"},
+					{Type: DTContext, Path: IndexAddr(1), Value: "a"},
+				}},
+			},
+		},
+		{"set insert before a delete", ArraySet,
+			`{"tags":["a","b","c","d"],"n":1}`,
+			`{"tags":["x","a","b","c"],"n":1}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("n"), Value: float64(1)},
+				{Type: DTContext, Path: StringAddr("tags"), Deltas: Deltas{
+					{Type: DTInsert, Path: IndexAddr(0), Value: "x"},
+					{Type: DTContext, Path: IndexAddr(1), Value: "a"},
+					{Type: DTContext, Path: IndexAddr(2), Value: "b"},
+					{Type: DTContext, Path: IndexAddr(3), Value: "c"},
+					{Type: DTDelete, Path: IndexAddr(4), Value: "d"},
+				}},
+			},
+		},
+		{"multiset delete between swapped members", ArrayMultiset,
+			`{"tags":["a","b","c"],"n":1}`,
+			`{"tags":["x","c","a"],"n":1}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("n"), Value: float64(1)},
+				{Type: DTContext, Path: StringAddr("tags"), Deltas: Deltas{
+					{Type: DTInsert, Path: IndexAddr(0), Value: "x"},
+					{Type: DTContext, Path: IndexAddr(1), Value: "c"},
+					{Type: DTDelete, Path: IndexAddr(2), Value: "b"},
+					{Type: DTContext, Path: IndexAddr(2), Value: "a"},
+				}},
+			},
+		},
+		{"wildcard paths", ArraySet,
+			`{"users":[{"roles":["x","y"]},{"roles":["z"]}]}`,
+			`{"users":[{"roles":["y","x"]},{"roles":["z","w"]}]}`,
+			Deltas{
+				{Type: DTContext, Path: StringAddr("users"), Deltas: Deltas{
+					{Type: DTContext, Path: IndexAddr(0), Value: map[string]interface{}{"roles": []interface{}{"y", "x"}}},
+					{Type: DTContext, Path: IndexAddr(1), Deltas: Deltas{
+						{Type: DTContext, Path: StringAddr("roles"), Deltas: Deltas{
+							{Type: DTContext, Path: IndexAddr(0), Value: "z"},
+							{Type: DTInsert, Path: IndexAddr(1), Value: "w"},
+						}},
+					}},
+				}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			var src, dst interface{}
+			if err := json.Unmarshal([]byte(c.src), &src); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(c.dst), &dst); err != nil {
+				t.Fatal(err)
+			}
+
+			dd := New(func(cfg *Config) {
+				cfg.ArrayModes = map[string]ArrayMode{
+					"/tags":          c.mode,
+					"/users/*/roles": c.mode,
+				}
+			})
+			diff, err := dd.Diff(context.Background(), src, dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := cmp.Diff(c.expect, diff); d != "" {
+				t.Errorf("diff script response mismatch (-want +got):\n%s", d)
+			}
+
+			// patching an unordered array needn't produce the same order, but must
+			// produce the same members. sets also ignore repeated members
+			if err := Patch(diff, &src); err != nil {
+				t.Fatal(err)
+			}
+			members := cmp.Transformer("sortTags", sortStrings)
+			if c.mode == ArraySet {
+				members = cmp.Transformer("setTags", setStrings)
+			}
+			if d := cmp.Diff(dst, src, members); d != "" {
+				t.Errorf("patched result mismatch (-want +got):\n%s", d)
+			}
+		})
+	}
+}
+
+func sortStrings(in []interface{}) []interface{} {
+	out := append([]interface{}(nil), in...)
+	sort.Slice(out, func(i, j int) bool {
+		a, aok := out[i].(string)
+		b, bok := out[j].(string)
+		return aok && bok && a < b
+	})
+	return out
+}
+
+func setStrings(in []interface{}) []interface{} {
+	var out []interface{}
+	for i, v := range sortStrings(in) {
+		if i == 0 || !reflect.DeepEqual(v, out[len(out)-1]) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func TestUnorderedHash(t *testing.T) {
+	hash := func(mode ArrayMode, v string) string {
+		var data interface{}
+		if err := json.Unmarshal([]byte(v), &data); err != nil {
+			t.Fatal(err)
+		}
+		d := New(func(cfg *Config) {
+			cfg.ArrayModes = map[string]ArrayMode{"/": mode}
+		}).newDiff(data, nil)
+		return hashStr(d.tree(data, RootAddr{}, nil, newArena(d.newHash)).Hash())
+	}
+
+	if hash(ArrayMultiset, `[1,2,2]`) != hash(ArrayMultiset, `[2,1,2]`) {
+		t.Error("expected multiset hash to be order-independent")
+	}
+	if hash(ArrayMultiset, `[1,2,2]`) == hash(ArrayMultiset, `[1,2]`) {
+		t.Error("expected multiset hash to count repeated elements")
+	}
+	if hash(ArraySet, `[1,2,2]`) != hash(ArraySet, `[2,1]`) {
+		t.Error("expected set hash to ignore repeated elements")
+	}
+	if hash(ArrayOrdered, `[1,2]`) == hash(ArrayOrdered, `[2,1]`) {
+		t.Error("expected ordered hash to depend on order")
+	}
+}
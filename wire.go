@@ -0,0 +1,605 @@
+package deepdiff
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// The binary wire format is a stream of delta scripts. Streams start with
+// wireMagic & a version byte, followed by any number of records. Each record
+// is a script: a count of deltas followed by the deltas.
+//
+// Every delta starts with a flag byte. The low 3 bits are the operation code,
+// where wireOpOther is followed by the operation as a string. Remaining bits
+// mark which optional fields follow the path, in order: value, source path,
+// source value, text diff, & child deltas.
+//
+// Integers are varints. Object keys, string paths & source paths are keys:
+// interned in a table shared by every record in the stream. A key is written
+// as 0 followed by the length-prefixed string the first time it's seen, which
+// adds it to the table, & as its position in the table plus one after that.
+// Values start with a tag byte naming their type
+var wireMagic = []byte("DDW")
+
+// wireVersion is the version of the binary wire format written by
+// DeltaEncoder
+const wireVersion = 1
+
+// wireMaxDepth bounds how deeply deltas & values can nest while decoding
+const wireMaxDepth = 1024
+
+// operation codes
+const (
+	wireOpContext = iota
+	wireOpDelete
+	wireOpInsert
+	wireOpUpdate
+	wireOpRename
+	wireOpOther = 7
+)
+
+var wireOps = map[Operation]byte{
+	DTContext: wireOpContext,
+	DTDelete:  wireOpDelete,
+	DTInsert:  wireOpInsert,
+	DTUpdate:  wireOpUpdate,
+	DTRename:  wireOpRename,
+}
+
+var wireOpNames = []Operation{DTContext, DTDelete, DTInsert, DTUpdate, DTRename}
+
+// delta field flags
+const (
+	wireHasValue       = 1 << 3
+	wireHasSourcePath  = 1 << 4
+	wireHasSourceValue = 1 << 5
+	wireHasTextDiff    = 1 << 6
+	wireHasDeltas      = 1 << 7
+)
+
+// path tags
+const (
+	wireRootAddr = iota
+	wireIndexAddr
+	wireStringAddr
+)
+
+// value tags
+const (
+	wireNull = iota
+	wireFalse
+	wireTrue
+	wireInt
+	// wireIntFloat is a float64 with an integer value, written as a varint
+	wireIntFloat
+	wireFloat
+	wireString
+	wireBytes
+	wireArray
+	wireObject
+)
+
+// DeltaEncoder writes delta scripts to a stream in a compact binary format
+type DeltaEncoder struct {
+	w       io.Writer
+	buf     *bytes.Buffer
+	keys    map[string]uint64
+	started bool
+}
+
+// NewDeltaEncoder creates an encoder that writes to w
+func NewDeltaEncoder(w io.Writer) *DeltaEncoder {
+	return &DeltaEncoder{w: w, buf: &bytes.Buffer{}, keys: map[string]uint64{}}
+}
+
+// Encode writes a delta script to the stream as a single record. Values are
+// converted to the types diff trees are built from, so int values decode as
+// int64. Encoding fails on values of any other type
+func (e *DeltaEncoder) Encode(ds Deltas) error {
+	e.buf.Reset()
+	if !e.started {
+		e.buf.Write(wireMagic)
+		e.buf.WriteByte(wireVersion)
+	}
+
+	// keys added while encoding a record that fails to encode aren't kept
+	mark := len(e.keys)
+	if err := e.deltas(ds); err != nil {
+		for key, i := range e.keys {
+			if i > uint64(mark) {
+				delete(e.keys, key)
+			}
+		}
+		return err
+	}
+
+	if _, err := e.w.Write(e.buf.Bytes()); err != nil {
+		return err
+	}
+	e.started = true
+	return nil
+}
+
+func (e *DeltaEncoder) uvarint(x uint64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf.Write(b[:binary.PutUvarint(b[:], x)])
+}
+
+func (e *DeltaEncoder) varint(x int64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf.Write(b[:binary.PutVarint(b[:], x)])
+}
+
+func (e *DeltaEncoder) str(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf.WriteString(s)
+}
+
+func (e *DeltaEncoder) key(s string) {
+	if i, ok := e.keys[s]; ok {
+		e.uvarint(i)
+		return
+	}
+	e.uvarint(0)
+	e.str(s)
+	e.keys[s] = uint64(len(e.keys) + 1)
+}
+
+func (e *DeltaEncoder) deltas(ds Deltas) error {
+	e.uvarint(uint64(len(ds)))
+	for _, d := range ds {
+		if err := e.delta(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *DeltaEncoder) delta(d *Delta) error {
+	op, known := wireOps[d.Type]
+	if !known {
+		op = wireOpOther
+	}
+	flags := op
+	if d.Value != nil {
+		flags |= wireHasValue
+	}
+	if d.SourcePath != "" {
+		flags |= wireHasSourcePath
+	}
+	if d.SourceValue != nil {
+		flags |= wireHasSourceValue
+	}
+	if len(d.TextDiff) > 0 {
+		flags |= wireHasTextDiff
+	}
+	if len(d.Deltas) > 0 {
+		flags |= wireHasDeltas
+	}
+
+	e.buf.WriteByte(flags)
+	if !known {
+		e.str(string(d.Type))
+	}
+	switch a := d.Path.(type) {
+	case RootAddr, nil:
+		e.buf.WriteByte(wireRootAddr)
+	case IndexAddr:
+		e.buf.WriteByte(wireIndexAddr)
+		e.varint(int64(a))
+	case StringAddr:
+		e.buf.WriteByte(wireStringAddr)
+		e.key(string(a))
+	default:
+		return fmt.Errorf("can't encode address type %T", d.Path)
+	}
+
+	if flags&wireHasValue != 0 {
+		if err := e.value(d.Value); err != nil {
+			return err
+		}
+	}
+	if flags&wireHasSourcePath != 0 {
+		e.key(d.SourcePath)
+	}
+	if flags&wireHasSourceValue != 0 {
+		if err := e.value(d.SourceValue); err != nil {
+			return err
+		}
+	}
+	if flags&wireHasTextDiff != 0 {
+		e.uvarint(uint64(len(d.TextDiff)))
+		for _, edit := range d.TextDiff {
+			op, ok := wireOps[edit.Type]
+			if !ok {
+				return fmt.Errorf("can't encode text edit operation %q", edit.Type)
+			}
+			e.buf.WriteByte(op)
+			e.str(edit.Text)
+		}
+	}
+	if flags&wireHasDeltas != 0 {
+		return e.deltas(d.Deltas)
+	}
+	return nil
+}
+
+func (e *DeltaEncoder) value(v interface{}) error {
+	switch x := preprocessType(v).(type) {
+	case nil:
+		e.buf.WriteByte(wireNull)
+	case bool:
+		if x {
+			e.buf.WriteByte(wireTrue)
+		} else {
+			e.buf.WriteByte(wireFalse)
+		}
+	case int64:
+		e.buf.WriteByte(wireInt)
+		e.varint(x)
+	case float64:
+		// integer floats are common in decoded JSON, & much smaller as varints
+		if x == math.Trunc(x) && math.Abs(x) < 1<<53 && !(x == 0 && math.Signbit(x)) {
+			e.buf.WriteByte(wireIntFloat)
+			e.varint(int64(x))
+			return nil
+		}
+		e.buf.WriteByte(wireFloat)
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(x))
+		e.buf.Write(b[:])
+	case string:
+		e.buf.WriteByte(wireString)
+		e.str(x)
+	case []byte:
+		e.buf.WriteByte(wireBytes)
+		e.uvarint(uint64(len(x)))
+		e.buf.Write(x)
+	case []interface{}:
+		e.buf.WriteByte(wireArray)
+		e.uvarint(uint64(len(x)))
+		for _, el := range x {
+			if err := e.value(el); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		e.buf.WriteByte(wireObject)
+		e.uvarint(uint64(len(x)))
+		for _, key := range keys {
+			e.key(key)
+			if err := e.value(x[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("can't encode value type %T", v)
+	}
+	return nil
+}
+
+// DeltaDecoder reads delta scripts from a stream written by DeltaEncoder
+type DeltaDecoder struct {
+	r       *bufio.Reader
+	keys    []string
+	started bool
+}
+
+// NewDeltaDecoder creates a decoder that reads from r. The decoder may read
+// data from r beyond the records it decodes
+func NewDeltaDecoder(r io.Reader) *DeltaDecoder {
+	return &DeltaDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next delta script from the stream, returning io.EOF once
+// the stream has no more records
+func (d *DeltaDecoder) Decode() (Deltas, error) {
+	if !d.started {
+		header := make([]byte, len(wireMagic)+1)
+		if _, err := io.ReadFull(d.r, header); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return nil, fmt.Errorf("invalid delta stream: missing header")
+			}
+			return nil, err
+		}
+		if !bytes.Equal(header[:len(wireMagic)], wireMagic) {
+			return nil, fmt.Errorf("invalid delta stream: missing header")
+		}
+		if v := header[len(wireMagic)]; v != wireVersion {
+			return nil, fmt.Errorf("unsupported delta stream version: %d", v)
+		}
+		d.started = true
+	}
+
+	if _, err := d.r.Peek(1); err == io.EOF {
+		return nil, io.EOF
+	}
+	return d.deltas(0)
+}
+
+// eof converts the end of a stream in the middle of a record to an error
+func eof(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (d *DeltaDecoder) uvarint() (uint64, error) {
+	x, err := binary.ReadUvarint(d.r)
+	return x, eof(err)
+}
+
+func (d *DeltaDecoder) varint() (int64, error) {
+	x, err := binary.ReadVarint(d.r)
+	return x, eof(err)
+}
+
+func (d *DeltaDecoder) byte() (byte, error) {
+	b, err := d.r.ReadByte()
+	return b, eof(err)
+}
+
+// bytes reads a length-prefixed byte string. Data is read as it arrives so a
+// corrupt length can't allocate more memory than the stream holds
+func (d *DeltaDecoder) bytes() ([]byte, error) {
+	l, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if l > math.MaxInt32 {
+		return nil, fmt.Errorf("invalid delta stream: string of %d bytes is too long", l)
+	}
+	buf := &bytes.Buffer{}
+	if n, err := io.CopyN(buf, d.r, int64(l)); err != nil {
+		if err == io.EOF && uint64(n) < l {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *DeltaDecoder) str() (string, error) {
+	b, err := d.bytes()
+	return string(b), err
+}
+
+func (d *DeltaDecoder) key() (string, error) {
+	i, err := d.uvarint()
+	if err != nil {
+		return "", err
+	}
+	if i == 0 {
+		s, err := d.str()
+		if err != nil {
+			return "", err
+		}
+		d.keys = append(d.keys, s)
+		return s, nil
+	}
+	if i > uint64(len(d.keys)) {
+		return "", fmt.Errorf("invalid delta stream: key %d isn't defined", i)
+	}
+	return d.keys[i-1], nil
+}
+
+// length reads a count of elements, capped for preallocation
+func (d *DeltaDecoder) length() (n uint64, capacity int, err error) {
+	if n, err = d.uvarint(); err != nil {
+		return 0, 0, err
+	}
+	capacity = 1024
+	if n < uint64(capacity) {
+		capacity = int(n)
+	}
+	return n, capacity, nil
+}
+
+func (d *DeltaDecoder) deltas(depth int) (Deltas, error) {
+	if depth > wireMaxDepth {
+		return nil, fmt.Errorf("invalid delta stream: exceeded max depth of %d", wireMaxDepth)
+	}
+	n, capacity, err := d.length()
+	if err != nil {
+		return nil, err
+	}
+	ds := make(Deltas, 0, capacity)
+	for i := uint64(0); i < n; i++ {
+		dlt, err := d.delta(depth)
+		if err != nil {
+			return nil, err
+		}
+		ds = append(ds, dlt)
+	}
+	return ds, nil
+}
+
+func (d *DeltaDecoder) delta(depth int) (*Delta, error) {
+	flags, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	dlt := &Delta{}
+	if op := flags & 7; op == wireOpOther {
+		str, err := d.str()
+		if err != nil {
+			return nil, err
+		}
+		dlt.Type = Operation(str)
+	} else if int(op) < len(wireOpNames) {
+		dlt.Type = wireOpNames[op]
+	} else {
+		return nil, fmt.Errorf("invalid delta stream: unknown operation code %d", op)
+	}
+
+	tag, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case wireRootAddr:
+		dlt.Path = RootAddr{}
+	case wireIndexAddr:
+		i, err := d.varint()
+		if err != nil {
+			return nil, err
+		}
+		dlt.Path = IndexAddr(i)
+	case wireStringAddr:
+		key, err := d.key()
+		if err != nil {
+			return nil, err
+		}
+		dlt.Path = StringAddr(key)
+	default:
+		return nil, fmt.Errorf("invalid delta stream: unknown path tag %d", tag)
+	}
+
+	if flags&wireHasValue != 0 {
+		if dlt.Value, err = d.value(depth); err != nil {
+			return nil, err
+		}
+	}
+	if flags&wireHasSourcePath != 0 {
+		if dlt.SourcePath, err = d.key(); err != nil {
+			return nil, err
+		}
+	}
+	if flags&wireHasSourceValue != 0 {
+		if dlt.SourceValue, err = d.value(depth); err != nil {
+			return nil, err
+		}
+	}
+	if flags&wireHasTextDiff != 0 {
+		n, capacity, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		dlt.TextDiff = make(TextDiff, 0, capacity)
+		for i := uint64(0); i < n; i++ {
+			op, err := d.byte()
+			if err != nil {
+				return nil, err
+			}
+			if int(op) >= len(wireOpNames) {
+				return nil, fmt.Errorf("invalid delta stream: unknown operation code %d", op)
+			}
+			text, err := d.str()
+			if err != nil {
+				return nil, err
+			}
+			dlt.TextDiff = append(dlt.TextDiff, TextEdit{Type: wireOpNames[op], Text: text})
+		}
+	}
+	if flags&wireHasDeltas != 0 {
+		if dlt.Deltas, err = d.deltas(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return dlt, nil
+}
+
+func (d *DeltaDecoder) value(depth int) (interface{}, error) {
+	if depth > wireMaxDepth {
+		return nil, fmt.Errorf("invalid delta stream: exceeded max depth of %d", wireMaxDepth)
+	}
+	tag, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case wireNull:
+		return nil, nil
+	case wireFalse:
+		return false, nil
+	case wireTrue:
+		return true, nil
+	case wireInt:
+		return d.varint()
+	case wireIntFloat:
+		i, err := d.varint()
+		return float64(i), err
+	case wireFloat:
+		var b [8]byte
+		if _, err := io.ReadFull(d.r, b[:]); err != nil {
+			return nil, eof(err)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b[:])), nil
+	case wireString:
+		return d.str()
+	case wireBytes:
+		return d.bytes()
+	case wireArray:
+		n, capacity, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		arr := make([]interface{}, 0, capacity)
+		for i := uint64(0); i < n; i++ {
+			el, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, el)
+		}
+		return arr, nil
+	case wireObject:
+		n, capacity, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		obj := make(map[string]interface{}, capacity)
+		for i := uint64(0); i < n; i++ {
+			key, err := d.key()
+			if err != nil {
+				return nil, err
+			}
+			if obj[key], err = d.value(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	}
+	return nil, fmt.Errorf("invalid delta stream: unknown value tag %d", tag)
+}
+
+// MarshalBinary encodes deltas as a binary wire format stream holding a
+// single record
+func (ds Deltas) MarshalBinary() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := NewDeltaEncoder(buf).Encode(ds); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes deltas encoded with MarshalBinary
+func (ds *Deltas) UnmarshalBinary(data []byte) error {
+	dec := NewDeltaDecoder(bytes.NewReader(data))
+	deltas, err := dec.Decode()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := dec.r.Peek(1); err != io.EOF {
+		return fmt.Errorf("invalid delta stream: unexpected data after deltas")
+	}
+	*ds = deltas
+	return nil
+}
@@ -0,0 +1,197 @@
+package deepdiff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDeltasBinary(t *testing.T) {
+	ds := Deltas{
+		{Type: DTDelete, Path: RootAddr{}},
+		{Type: DTContext, Path: StringAddr("0"), Deltas: Deltas{
+			{Type: DTInsert, Path: IndexAddr(0), Value: []interface{}{"a", int64(-7), 1.5, 0.1, 3.0, math.Copysign(0, -1), math.MaxFloat64, true, false, nil, []byte{0xff}}},
+			{Type: DTRename, Path: StringAddr("to"), SourcePath: "from", Value: map[string]interface{}{"bb": nil, "a": int64(1000), "0": map[string]interface{}{}}},
+			{Type: DTUpdate, Path: IndexAddr(-1), Value: "new", SourceValue: "old"},
+			{Type: DTUpdate, Path: StringAddr("text"), Value: "a big dog", SourceValue: "a dog", TextDiff: TextDiff{
+				{Type: DTContext, Text: "a "},
+				{Type: DTInsert, Text: "big "},
+				{Type: DTContext, Text: "dog"},
+			}},
+		}},
+		{Type: DTRename, Path: StringAddr("c"), SourcePath: "d", Deltas: Deltas{
+			{Type: DTUpdate, Path: IndexAddr(3), Value: "x", SourceValue: []interface{}{}},
+		}},
+		{Type: Operation("?"), Path: StringAddr("from")},
+	}
+
+	data, err := ds.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonData, err := json.Marshal(ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) >= len(jsonData) {
+		t.Errorf("expected binary encoding to be smaller than JSON. binary: %d bytes, json: %d bytes", len(data), len(jsonData))
+	}
+
+	got := Deltas{}
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(ds, got); diff != "" {
+		t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+	}
+	if !math.Signbit(got[1].Deltas[0].Value.([]interface{})[5].(float64)) {
+		t.Error("expected negative zero to keep its sign")
+	}
+
+	// values are converted to diff tree types
+	data, err = (Deltas{{Type: DTInsert, Path: IndexAddr(0), Value: []interface{}{1, uint8(2), float32(0.5)}}}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]interface{}{int64(1), int64(2), 0.5}, got[0].Value); diff != "" {
+		t.Errorf("value mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDeltasBinaryDiff(t *testing.T) {
+	a := map[string]interface{}{
+		"title": "the quick brown fox",
+		"tags":  []interface{}{"a", "b", "c"},
+		"count": int64(3),
+		"meta":  map[string]interface{}{"id": "a", "rating": 4.5},
+	}
+	b := map[string]interface{}{
+		"title": "the quick red fox",
+		"tags":  []interface{}{"a", "c", "d"},
+		"count": int64(4),
+		"info":  map[string]interface{}{"id": "a", "rating": 4.5},
+	}
+
+	dd := New(func(cfg *Config) {
+		cfg.CalcChanges = true
+		cfg.RenameThreshold = 1
+	})
+	ds, err := dd.Diff(context.Background(), a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ds.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Deltas{}
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(ds, got); diff != "" {
+		t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDeltaEncoderStream(t *testing.T) {
+	scripts := []Deltas{
+		{{Type: DTUpdate, Path: StringAddr("name"), Value: "b", SourceValue: "a"}},
+		{},
+		{{Type: DTInsert, Path: StringAddr("name"), Value: map[string]interface{}{"name": "c"}}},
+		{{Type: DTDelete, Path: IndexAddr(2), Value: "x"}},
+	}
+
+	buf := &bytes.Buffer{}
+	enc := NewDeltaEncoder(buf)
+	for _, ds := range scripts {
+		if err := enc.Encode(ds); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a failed record isn't written & doesn't affect the key table
+	if err := enc.Encode(Deltas{{Type: DTInsert, Path: StringAddr("bad"), Value: struct{}{}}}); err == nil {
+		t.Error("expected error encoding unsupported value type")
+	}
+	if err := enc.Encode(Deltas{{Type: DTInsert, Path: StringAddr("good"), Value: int64(1)}}); err != nil {
+		t.Fatal(err)
+	}
+	scripts = append(scripts, Deltas{{Type: DTInsert, Path: StringAddr("good"), Value: int64(1)}})
+
+	// keys repeated across records are written once
+	if n := bytes.Count(buf.Bytes(), []byte("name")); n != 1 {
+		t.Errorf("expected key to be written once, got %d", n)
+	}
+
+	dec := NewDeltaDecoder(buf)
+	for i, expect := range scripts {
+		got, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("record %d: %s", i, err)
+		}
+		if diff := cmp.Diff(expect, got); diff != "" {
+			t.Errorf("record %d mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("expected io.EOF at end of stream, got: %v", err)
+	}
+
+	if _, err := NewDeltaDecoder(&bytes.Buffer{}).Decode(); err != io.EOF {
+		t.Errorf("expected io.EOF decoding empty stream, got: %v", err)
+	}
+}
+
+func TestDeltaDecoderErrors(t *testing.T) {
+	valid, err := (Deltas{{Type: DTUpdate, Path: StringAddr("a"), Value: []interface{}{"b", 1.5}, SourceValue: "c"}}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := string(wireMagic) + "\x01"
+
+	cases := []struct {
+		description string
+		data        []byte
+	}{
+		{"bad magic", []byte("XXX\x01\x00")},
+		{"unknown version", []byte(string(wireMagic) + "\x02\x00")},
+		{"short header", wireMagic},
+		{"no records", []byte(header)},
+		{"trailing data", append(valid[:len(valid):len(valid)], 0)},
+		{"unknown operation", []byte(header + "\x01\x05\x00")},
+		{"unknown path tag", []byte(header + "\x01\x00\x09")},
+		{"undefined key", []byte(header + "\x01\x00\x02\x01")},
+		{"unknown value tag", []byte(header + "\x01\x0a\x00\x20")},
+		{"huge string", []byte(header + "\x01\x0a\x00\x06\xff\xff\xff\xff\x0f")},
+		{"huge array", []byte(header + "\x01\x0a\x00\x08\xff\xff\xff\xff\x0f")},
+	}
+	for i := 1; i < len(valid); i++ {
+		cases = append(cases, struct {
+			description string
+			data        []byte
+		}{"truncated", valid[:i]})
+	}
+
+	for _, c := range cases {
+		if err := (&Deltas{}).UnmarshalBinary(c.data); err == nil {
+			t.Errorf("%s: expected error decoding %q", c.description, c.data)
+		}
+	}
+
+	deep := []byte(header + "\x01\x08\x00")
+	for i := 0; i <= wireMaxDepth; i++ {
+		deep = append(deep, wireArray, 1)
+	}
+	deep = append(deep, wireNull)
+	if err := (&Deltas{}).UnmarshalBinary(deep); err == nil {
+		t.Error("expected error decoding values nested past max depth")
+	}
+}
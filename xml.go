@@ -0,0 +1,306 @@
+package deepdiff
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	// XMLAttrPrefix starts the keys of attributes in decoded XML elements
+	XMLAttrPrefix = "@"
+	// XMLTextKey is the key of text in decoded XML elements
+	XMLTextKey = "#text"
+)
+
+// DecodeXML reads an XML document as an object with a single key, the name
+// of the root element. Elements decode as objects: attributes are keyed by
+// name prefixed with XMLAttrPrefix, text is keyed by XMLTextKey, and child
+// elements are grouped by name into arrays, so every element is addressed by
+// its name & its index among siblings of the same name. The order of siblings
+// with different names isn't kept.
+// Names in a namespace are written {namespace}name. Text is trimmed of
+// surrounding whitespace & elements without text don't have a text key.
+// Comments, processing instructions & namespace declarations are dropped
+func DecodeXML(r io.Reader) (map[string]interface{}, error) {
+	type element struct {
+		name  string
+		value map[string]interface{}
+		text  bytes.Buffer
+	}
+
+	var (
+		dec   = xml.NewDecoder(r)
+		stack []*element
+		root  map[string]interface{}
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && root != nil {
+				return nil, fmt.Errorf("invalid XML: more than one root element")
+			}
+			el := &element{name: xmlName(t.Name), value: map[string]interface{}{}}
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue
+				}
+				el.value[XMLAttrPrefix+xmlName(attr.Name)] = attr.Value
+			}
+			stack = append(stack, el)
+		case xml.EndElement:
+			el := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if text := strings.TrimSpace(el.text.String()); text != "" {
+				el.value[XMLTextKey] = text
+			}
+			if len(stack) == 0 {
+				root = map[string]interface{}{el.name: el.value}
+				continue
+			}
+			parent := stack[len(stack)-1].value
+			siblings, _ := parent[el.name].([]interface{})
+			parent[el.name] = append(siblings, el.value)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("invalid XML: no root element")
+	}
+	return root, nil
+}
+
+// xmlName writes a name in {namespace}local form
+func xmlName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return "{" + n.Space + "}" + n.Local
+}
+
+// DiffXML computes deltas between two XML documents, decoded with DecodeXML
+func (dd *DeepDiff) DiffXML(ctx context.Context, r1, r2 io.Reader) (Deltas, error) {
+	a, err := DecodeXML(r1)
+	if err != nil {
+		return nil, err
+	}
+	b, err := DecodeXML(r2)
+	if err != nil {
+		return nil, err
+	}
+	return dd.Diff(ctx, a, b)
+}
+
+// FormatPrettyXMLString is a convenience wrapper that outputs to a string
+// instead of an io.Writer
+func FormatPrettyXMLString(changes Deltas, colorTTY bool, opts ...FormatOption) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := FormatPrettyXML(buf, changes, colorTTY, opts...); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// FormatPrettyXML writes a text report of changes to documents decoded with
+// DecodeXML, one line per change. Changes are addressed by XPath, eg:
+// /catalog/book[2]/@id, and elements are written as XML. Updates show the
+// source value & the new value. Unchanged values aren't written. Of the
+// value limits set with opts, only MaxValueLength applies
+func FormatPrettyXML(w io.Writer, changes Deltas, colorTTY bool, opts ...FormatOption) error {
+	return formatPrettyXML(w, changes, nil, newFormatConfig(colorTTY, opts))
+}
+
+func formatPrettyXML(w io.Writer, changes Deltas, path []Addr, cfg *FormatConfig) error {
+	for _, d := range changes {
+		p := append(path[:len(path):len(path)], d.Path)
+		if len(d.Deltas) > 0 {
+			if d.Type == DTRename {
+				src := append(path[:len(path):len(path)], StringAddr(d.SourcePath))
+				fmt.Fprintf(w, "%s%s%s -> %s%s\n", cfg.Styler.Start(d.Type), d.Type, xpath(src), xpath(p), cfg.Styler.End(d.Type))
+			}
+			if err := formatPrettyXML(w, d.Deltas, p, cfg); err != nil {
+				return err
+			}
+			continue
+		}
+		if d.Type == DTContext {
+			continue
+		}
+
+		str := cfg.xmlValue(p, d.Value)
+		loc := xpath(p)
+		switch d.Type {
+		case DTUpdate:
+			if d.SourceValue != nil {
+				str = cfg.xmlValue(p, d.SourceValue) + " -> " + str
+			}
+		case DTRename:
+			loc = xpath(append(path[:len(path):len(path)], StringAddr(d.SourcePath))) + " -> " + loc
+		}
+		fmt.Fprintf(w, "%s%s%s: %s%s\n", cfg.Styler.Start(d.Type), d.Type, loc, str, cfg.Styler.End(d.Type))
+	}
+	return nil
+}
+
+// xpath writes a path to a value of a decoded XML document as an XPath
+// expression. Indices count from 1
+func xpath(path []Addr) string {
+	buf := &strings.Builder{}
+	for _, addr := range path {
+		switch a := addr.(type) {
+		case IndexAddr:
+			fmt.Fprintf(buf, "[%d]", int(a)+1)
+		case StringAddr:
+			if a == XMLTextKey {
+				buf.WriteString("/text()")
+			} else {
+				buf.WriteString("/" + string(a))
+			}
+		}
+	}
+	if buf.Len() == 0 {
+		return "/"
+	}
+	return buf.String()
+}
+
+// xmlValue renders the value at a path of a decoded XML document. Elements &
+// lists of same-named elements render as XML, attributes & text render as
+// quoted strings
+func (cfg *FormatConfig) xmlValue(path []Addr, v interface{}) string {
+	var str string
+	name := xmlElementName(path)
+	switch x := v.(type) {
+	case map[string]interface{}:
+		if name == "" {
+			// the document root, an object keyed by root element name
+			buf := &strings.Builder{}
+			writeXMLChildren(buf, x)
+			str = buf.String()
+		} else {
+			buf := &strings.Builder{}
+			writeXMLElement(buf, name, x)
+			str = buf.String()
+		}
+	case []interface{}:
+		buf := &strings.Builder{}
+		for _, el := range x {
+			if obj, ok := el.(map[string]interface{}); ok {
+				writeXMLElement(buf, name, obj)
+			}
+		}
+		str = buf.String()
+	default:
+		str = fmt.Sprintf("%q", fmt.Sprint(v))
+	}
+
+	if cfg.MaxValueLength > 0 && len(str) > cfg.MaxValueLength {
+		str = truncateString(str, cfg.MaxValueLength-len("..."))
+	}
+	return str
+}
+
+// xmlElementName finds the name of the element or elements at the end of a
+// path, empty if the path doesn't end at elements
+func xmlElementName(path []Addr) string {
+	if len(path) == 0 {
+		return ""
+	}
+	last := path[len(path)-1]
+	if _, ok := last.(IndexAddr); ok && len(path) > 1 {
+		last = path[len(path)-2]
+	}
+	if key, ok := last.(StringAddr); ok && !strings.HasPrefix(string(key), XMLAttrPrefix) && key != XMLTextKey {
+		return string(key)
+	}
+	return ""
+}
+
+// writeXMLElement writes a decoded element as XML. Attributes & child
+// elements are written in order of name
+func writeXMLElement(buf *strings.Builder, name string, el map[string]interface{}) {
+	name = localName(name)
+	keys := make([]string, 0, len(el))
+	for key := range el {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf.WriteString("<" + name)
+	for _, key := range keys {
+		if strings.HasPrefix(key, XMLAttrPrefix) {
+			buf.WriteString(" " + localName(key[len(XMLAttrPrefix):]) + `="`)
+			xml.EscapeText(buf, []byte(fmt.Sprint(el[key])))
+			buf.WriteByte('"')
+		}
+	}
+	text, hasText := el[XMLTextKey]
+	if !hasText && !hasXMLChildren(el) {
+		buf.WriteString("/>")
+		return
+	}
+	buf.WriteByte('>')
+	if hasText {
+		xml.EscapeText(buf, []byte(fmt.Sprint(text)))
+	}
+	writeXMLChildren(buf, el)
+	buf.WriteString("</" + name + ">")
+}
+
+// writeXMLChildren writes the child elements of a decoded element in order of
+// name
+func writeXMLChildren(buf *strings.Builder, el map[string]interface{}) {
+	keys := make([]string, 0, len(el))
+	for key := range el {
+		if !strings.HasPrefix(key, XMLAttrPrefix) && key != XMLTextKey {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch x := el[key].(type) {
+		case []interface{}:
+			for _, ch := range x {
+				if obj, ok := ch.(map[string]interface{}); ok {
+					writeXMLElement(buf, key, obj)
+				}
+			}
+		case map[string]interface{}:
+			writeXMLElement(buf, key, x)
+		}
+	}
+}
+
+func hasXMLChildren(el map[string]interface{}) bool {
+	for key := range el {
+		if !strings.HasPrefix(key, XMLAttrPrefix) && key != XMLTextKey {
+			return true
+		}
+	}
+	return false
+}
+
+// localName drops the namespace from a {namespace}local name
+func localName(name string) string {
+	if i := strings.LastIndex(name, "}"); strings.HasPrefix(name, "{") && i > 0 {
+		return name[i+1:]
+	}
+	return name
+}
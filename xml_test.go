@@ -0,0 +1,150 @@
+package deepdiff
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecodeXML(t *testing.T) {
+	cases := []struct {
+		description string
+		input       string
+		expect      map[string]interface{}
+	}{
+		{"empty root",
+			`<a/>`,
+			map[string]interface{}{"a": map[string]interface{}{}},
+		},
+		{"attributes, text & children",
+			`<?xml version="1.0"?>
+			<!-- comment -->
+			<a id="1">
+				text
+				<b>one</b>
+				<c/>
+				<b x="y">two</b>
+			</a>`,
+			map[string]interface{}{"a": map[string]interface{}{
+				"@id":   "1",
+				"#text": "text",
+				"b": []interface{}{
+					map[string]interface{}{"#text": "one"},
+					map[string]interface{}{"@x": "y", "#text": "two"},
+				},
+				"c": []interface{}{map[string]interface{}{}},
+			}},
+		},
+		{"namespaces",
+			`<a xmlns="urn:a" xmlns:z="urn:z" z:id="1"><z:b><![CDATA[<raw>]]></z:b></a>`,
+			map[string]interface{}{"{urn:a}a": map[string]interface{}{
+				"@{urn:z}id": "1",
+				"{urn:z}b": []interface{}{
+					map[string]interface{}{"#text": "<raw>"},
+				},
+			}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			got, err := DecodeXML(strings.NewReader(c.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.expect, got); diff != "" {
+				t.Errorf("result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDecodeXMLErrors(t *testing.T) {
+	cases := []string{
+		``,
+		`<!-- no root -->`,
+		`<a><b></a>`,
+		`<a/><b/>`,
+	}
+
+	for i, c := range cases {
+		if _, err := DecodeXML(strings.NewReader(c)); err == nil {
+			t.Errorf("case %d: expected error, got nil", i)
+		}
+	}
+}
+
+func TestDiffXML(t *testing.T) {
+	a := `<catalog>
+		<book id="1"><title>Go</title><price>10</price></book>
+		<book id="2"><title>XML</title><price>20</price></book>
+		<note>hi</note>
+	</catalog>`
+	b := `<catalog>
+		<book id="1"><title>Go</title><price>12</price></book>
+		<book id="3"><title>New &amp; shiny</title><price>5</price></book>
+		<book id="2"><title>XML</title><price>20</price></book>
+	</catalog>`
+
+	deltas, err := New(func(cfg *Config) { cfg.CalcChanges = true }).DiffXML(context.Background(), strings.NewReader(a), strings.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := DecodeXML(strings.NewReader(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := DecodeXML(strings.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPatch(deltas, src, dst); err != nil {
+		t.Error(err)
+	}
+
+	got, err := FormatPrettyXMLString(deltas, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `~/catalog/book[1]/price[1]/text(): "10" -> "12"
++/catalog/book[2]: <book id="3"><price>5</price><title>New &amp; shiny</title></book>
+-/catalog/note: <note>hi</note>
+`
+	if got != expect {
+		t.Errorf("format mismatch.\nwant:\n%s\ngot:\n%s", expect, got)
+	}
+
+	if _, err := New().DiffXML(context.Background(), strings.NewReader(a), strings.NewReader("<a>")); err == nil {
+		t.Error("expected error decoding invalid XML")
+	}
+}
+
+func TestFormatPrettyXML(t *testing.T) {
+	changes := Deltas{
+		{Type: DTDelete, Path: RootAddr{}, Value: map[string]interface{}{"a": map[string]interface{}{"@id": "1"}}},
+		{Type: DTInsert, Path: RootAddr{}, Value: map[string]interface{}{"b": map[string]interface{}{"#text": "x"}}},
+		{Type: DTContext, Path: StringAddr("c"), Deltas: Deltas{
+			{Type: DTRename, Path: StringAddr("@to"), SourcePath: "@from", Value: "v"},
+			{Type: DTInsert, Path: StringAddr("d"), Value: []interface{}{
+				map[string]interface{}{"#text": "1"},
+				map[string]interface{}{"#text": "2"},
+			}},
+		}},
+	}
+
+	got, err := FormatPrettyXMLString(changes, false, func(cfg *FormatConfig) { cfg.MaxValueLength = 12 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `-/: <a id="1"/>
++/: <b>x</b>
+^/c/@from -> /c/@to: "v"
++/c/d: <d>1</d><...
+`
+	if got != expect {
+		t.Errorf("format mismatch.\nwant:\n%s\ngot:\n%s", expect, got)
+	}
+}